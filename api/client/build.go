@@ -39,10 +39,10 @@ type translatorFunc func(context.Context, reference.NamedTagged) (reference.Cano
 
 // CmdBuild builds a new image from the source code at a given path.
 //
-// If '-' is provided instead of a path or URL, Docker will build an image from either a Dockerfile or tar archive read from STDIN.
+// If '-' is provided instead of a path or URL, Hyper will build an image from either a Dockerfile or tar archive read from STDIN.
 //
-// Usage: docker build [OPTIONS] PATH | URL | -
-func (cli *DockerCli) Build(args ...string) error {
+// Usage: hyper build [OPTIONS] PATH | URL | -
+func (cli *DockerCli) CmdBuild(args ...string) error {
 	cmd := Cli.Subcmd("build", []string{"PATH | URL | -"}, Cli.DockerCommands["build"].Description, true)
 	flTags := opts.NewListOpts(validateTag)
 	cmd.Var(&flTags, []string{"t", "-tag"}, "Name and optionally a tag in the 'name:tag' format")
@@ -75,19 +75,11 @@ func (cli *DockerCli) Build(args ...string) error {
 
 	cmd.ParseFlags(args, true)
 
-	var (
-		buildCtx io.ReadCloser
-		err      error
-	)
-
 	specifiedContext := cmd.Arg(0)
 
 	var (
-		contextDir    string
-		tempDir       string
-		relDockerfile string
-		progBuff      io.Writer
-		buildBuff     io.Writer
+		progBuff  io.Writer
+		buildBuff io.Writer
 	)
 
 	progBuff = cli.out
@@ -97,76 +89,14 @@ func (cli *DockerCli) Build(args ...string) error {
 		buildBuff = bytes.NewBuffer(nil)
 	}
 
-	switch {
-	case specifiedContext == "-":
-		buildCtx, relDockerfile, err = getContextFromReader(cli.in, *dockerfileName)
-	case urlutil.IsGitURL(specifiedContext):
-		tempDir, relDockerfile, err = getContextFromGitURL(specifiedContext, *dockerfileName)
-	case urlutil.IsURL(specifiedContext):
-		buildCtx, relDockerfile, err = getContextFromURL(progBuff, specifiedContext, *dockerfileName)
-	default:
-		contextDir, relDockerfile, err = getContextFromLocalDir(specifiedContext, *dockerfileName)
-	}
-
+	buildCtx, relDockerfile, cleanup, err := prepareBuildContext(cli.in, progBuff, specifiedContext, *dockerfileName)
 	if err != nil {
 		if *suppressOutput && urlutil.IsURL(specifiedContext) {
 			fmt.Fprintln(cli.err, progBuff)
 		}
-		return fmt.Errorf("unable to prepare context: %s", err)
-	}
-
-	if tempDir != "" {
-		defer os.RemoveAll(tempDir)
-		contextDir = tempDir
-	}
-
-	if buildCtx == nil {
-		// And canonicalize dockerfile name to a platform-independent one
-		relDockerfile, err = archive.CanonicalTarNameForPath(relDockerfile)
-		if err != nil {
-			return fmt.Errorf("cannot canonicalize dockerfile path %s: %v", relDockerfile, err)
-		}
-
-		f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
-		if err != nil && !os.IsNotExist(err) {
-			return err
-		}
-
-		var excludes []string
-		if err == nil {
-			excludes, err = dockerignore.ReadAll(f)
-			if err != nil {
-				return err
-			}
-		}
-
-		if err := validateContextDirectory(contextDir, excludes); err != nil {
-			return fmt.Errorf("Error checking context: '%s'.", err)
-		}
-
-		// If .dockerignore mentions .dockerignore or the Dockerfile
-		// then make sure we send both files over to the daemon
-		// because Dockerfile is, obviously, needed no matter what, and
-		// .dockerignore is needed to know if either one needs to be
-		// removed. The daemon will remove them for us, if needed, after it
-		// parses the Dockerfile. Ignore errors here, as they will have been
-		// caught by validateContextDirectory above.
-		var includes = []string{"."}
-		keepThem1, _ := fileutils.Matches(".dockerignore", excludes)
-		keepThem2, _ := fileutils.Matches(relDockerfile, excludes)
-		if keepThem1 || keepThem2 {
-			includes = append(includes, ".dockerignore", relDockerfile)
-		}
-
-		buildCtx, err = archive.TarWithOptions(contextDir, &archive.TarOptions{
-			Compression:     archive.Uncompressed,
-			ExcludePatterns: excludes,
-			IncludeFiles:    includes,
-		})
-		if err != nil {
-			return err
-		}
+		return err
 	}
+	defer cleanup()
 
 	ctx := context.Background()
 
@@ -180,7 +110,7 @@ func (cli *DockerCli) Build(args ...string) error {
 	// Setup an upload progress bar
 	progressOutput := streamformatter.NewStreamFormatter().NewProgressOutput(progBuff, true)
 
-	var body io.Reader = progress.NewProgressReader(buildCtx, progressOutput, 0, "", "Sending build context to Docker daemon")
+	var body io.Reader = progress.NewProgressReader(buildCtx, progressOutput, 0, "", "Sending build context to Hyper")
 
 	var memory int64
 	if *flMemoryString != "" {
@@ -278,6 +208,96 @@ func (cli *DockerCli) Build(args ...string) error {
 	return nil
 }
 
+// prepareBuildContext returns the tar stream of a build context along with
+// the path of the Dockerfile in it. The context is a local directory, a git
+// repository, the URL of a tarball or Dockerfile, or "-" to read it from in.
+// The returned function removes the temporary files of the context.
+func prepareBuildContext(in io.ReadCloser, progBuff io.Writer, specifiedContext, dockerfileName string) (io.ReadCloser, string, func(), error) {
+	var (
+		buildCtx      io.ReadCloser
+		contextDir    string
+		tempDir       string
+		relDockerfile string
+		err           error
+	)
+	cleanup := func() {}
+
+	switch {
+	case specifiedContext == "-":
+		buildCtx, relDockerfile, err = getContextFromReader(in, dockerfileName)
+	case urlutil.IsGitURL(specifiedContext):
+		tempDir, relDockerfile, err = getContextFromGitURL(specifiedContext, dockerfileName)
+	case urlutil.IsURL(specifiedContext):
+		buildCtx, relDockerfile, err = getContextFromURL(progBuff, specifiedContext, dockerfileName)
+	default:
+		contextDir, relDockerfile, err = getContextFromLocalDir(specifiedContext, dockerfileName)
+	}
+
+	if err != nil {
+		return nil, "", cleanup, fmt.Errorf("unable to prepare context: %s", err)
+	}
+
+	if tempDir != "" {
+		cleanup = func() { os.RemoveAll(tempDir) }
+		contextDir = tempDir
+	}
+
+	if buildCtx == nil {
+		// And canonicalize dockerfile name to a platform-independent one
+		relDockerfile, err = archive.CanonicalTarNameForPath(relDockerfile)
+		if err != nil {
+			cleanup()
+			return nil, "", cleanup, fmt.Errorf("cannot canonicalize dockerfile path %s: %v", relDockerfile, err)
+		}
+
+		f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
+		if err != nil && !os.IsNotExist(err) {
+			cleanup()
+			return nil, "", cleanup, err
+		}
+
+		var excludes []string
+		if err == nil {
+			excludes, err = dockerignore.ReadAll(f)
+			f.Close()
+			if err != nil {
+				cleanup()
+				return nil, "", cleanup, err
+			}
+		}
+
+		if err := validateContextDirectory(contextDir, excludes); err != nil {
+			cleanup()
+			return nil, "", cleanup, fmt.Errorf("Error checking context: '%s'.", err)
+		}
+
+		// If .dockerignore mentions .dockerignore or the Dockerfile
+		// then make sure we send both files over to the daemon
+		// because Dockerfile is, obviously, needed no matter what, and
+		// .dockerignore is needed to know if either one needs to be
+		// removed. The daemon will remove them for us, if needed, after it
+		// parses the Dockerfile. Ignore errors here, as they will have been
+		// caught by validateContextDirectory above.
+		var includes = []string{"."}
+		keepThem1, _ := fileutils.Matches(".dockerignore", excludes)
+		keepThem2, _ := fileutils.Matches(relDockerfile, excludes)
+		if keepThem1 || keepThem2 {
+			includes = append(includes, ".dockerignore", relDockerfile)
+		}
+
+		buildCtx, err = archive.TarWithOptions(contextDir, &archive.TarOptions{
+			Compression:     archive.Uncompressed,
+			ExcludePatterns: excludes,
+			IncludeFiles:    includes,
+		})
+		if err != nil {
+			cleanup()
+			return nil, "", cleanup, err
+		}
+	}
+	return buildCtx, relDockerfile, cleanup, nil
+}
+
 // validateContextDirectory checks if all the contents of the directory
 // can be read and returns an error if some files can't be read
 // symlinks which point to non-existing files don't trigger an error
@@ -50,6 +50,9 @@ type DockerCli struct {
 	state  *term.State
 	region string
 	host   string
+	// regionClient returns a client of another region of the default
+	// endpoint, with the same credentials.
+	regionClient func(region string) (client.APIClient, error)
 }
 
 // Initialize calls the init function that will setup the configuration for the client
@@ -91,6 +94,18 @@ func (cli *DockerCli) VolumesFormat() string {
 	return cli.configFile.VolumesFormat
 }
 
+// SnapshotsFormat returns the format string specified in the configuration.
+// String contains columns and format specification, for example {{Name}}\t{{Volume}}.
+func (cli *DockerCli) SnapshotsFormat() string {
+	return cli.configFile.SnapshotsFormat
+}
+
+// StatsFormat returns the format string specified in the configuration.
+// String contains columns and format specification, for example {{.Container}}\t{{.CPUPerc}}.
+func (cli *DockerCli) StatsFormat() string {
+	return cli.configFile.StatsFormat
+}
+
 func (cli *DockerCli) setRawTerminal() error {
 	if cli.isTerminalIn && os.Getenv("NORAW") == "" {
 		state, err := term.SetRawTerminal(cli.inFd)
@@ -186,6 +201,25 @@ func NewDockerCli(in io.ReadCloser, out, err io.Writer, clientFlags *cli.ClientF
 			}
 		}
 
+		cli.regionClient = func(region string) (client.APIClient, error) {
+			if !dft {
+				return nil, fmt.Errorf("listing several regions is only supported with the default endpoint, not %s", host)
+			}
+			regionHost, err := opts.ParseHost(clientFlags.Common.TLSOptions != nil, "tcp://"+region+"."+cliconfig.DefaultHyperEndpoint)
+			if err != nil {
+				return nil, err
+			}
+			httpClient, err := newHTTPClient(regionHost, clientFlags.Common.TLSOptions)
+			if err != nil {
+				return nil, err
+			}
+			c, err := client.NewClient(regionHost, verStr, httpClient, customHeaders, cloudConfig.AccessKey, cloudConfig.SecretKey, region)
+			if err != nil {
+				return nil, err
+			}
+			return c, nil
+		}
+
 		client, err := client.NewClient(host, verStr, httpClient, customHeaders, cloudConfig.AccessKey, cloudConfig.SecretKey, cli.region)
 		if err != nil {
 			return err
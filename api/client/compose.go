@@ -5,17 +5,20 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/Sirupsen/logrus"
+	yaml "github.com/cloudfoundry-incubator/candiedyaml"
 	"github.com/hyperhq/hyper-api/client"
-	"github.com/hyperhq/hyper-api/types"
-	"github.com/hyperhq/hyper-api/types/filters"
 	Cli "github.com/hyperhq/hypercli/cli"
+	"github.com/hyperhq/hypercli/opts"
 	"github.com/hyperhq/hypercli/pkg/jsonmessage"
 	flag "github.com/hyperhq/hypercli/pkg/mflag"
+	"github.com/hyperhq/libcompose/config"
 	"github.com/hyperhq/libcompose/docker"
 	"github.com/hyperhq/libcompose/logger"
 	"github.com/hyperhq/libcompose/project"
@@ -25,6 +28,11 @@ import (
 
 const ComposeFipAuto = "auto"
 
+const (
+	defaultComposeFile         = "docker-compose.yml"
+	defaultComposeOverrideFile = "docker-compose.override.yml"
+)
+
 // CmdCompose is the parent subcommand for all compose commands
 //
 // Usage: hyper compose <COMMAND> [OPTIONS]
@@ -41,7 +49,8 @@ func (cli *DockerCli) CmdCompose(args ...string) error {
 // Usage: hyper compose run [OPTIONS] SERVICE [COMMAND] [ARGS...]
 func (cli *DockerCli) CmdComposeRun(args ...string) error {
 	cmd := Cli.Subcmd("compose run", []string{"SERVICE [COMMAND] [ARGS...]"}, "Run a one-off command on a service", false)
-	composeFile := cmd.String([]string{"f", "-file"}, "docker-compose.yml", "Specify an alternate compose file")
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
 	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
 	rm := cmd.Bool([]string{"-rm"}, false, "Remove container after run, ignored in detached mode")
 
@@ -50,12 +59,13 @@ func (cli *DockerCli) CmdComposeRun(args ...string) error {
 	if err != nil {
 		return err
 	}
+	files := resolveComposeFiles(composeFiles.GetAll())
 	if *projectName == "" {
 		*projectName = getBaseDir()
 	}
 	project, err := docker.NewProject(&docker.Context{
 		Context: project.Context{
-			ComposeFiles: []string{*composeFile},
+			ComposeFiles: files,
 			ProjectName:  *projectName,
 			Autoremove:   *rm,
 		},
@@ -66,6 +76,10 @@ func (cli *DockerCli) CmdComposeRun(args ...string) error {
 		return err
 	}
 	service := cmd.Args()[0]
+	c, _, _ := project.GetConfig()
+	if err := cli.buildComposeImages(context.Background(), *projectName, c, nil, composeBuildOptions{}); err != nil {
+		return err
+	}
 	status, err := project.Run(context.Background(), service, cmd.Args()[1:])
 	if err != nil {
 		return err
@@ -88,6 +102,8 @@ func (cli *DockerCli) CmdComposeRun(args ...string) error {
 // Usage: hyper compose down [OPTIONS]
 func (cli *DockerCli) CmdComposeDown(args ...string) error {
 	cmd := Cli.Subcmd("compose down", []string{}, "Stop and remove containers, images, and volumes\ncreated by `up`. Only containers and networks are removed by default.", false)
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
 	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
 	rmi := cmd.String([]string{"-rmi"}, "", "Remove images, type may be one of: 'all' to remove\nall images, or 'local' to remove only images that\ndon't have an custom name set by the `image` field")
 	vol := cmd.Bool([]string{"v", "-volumes"}, false, "Remove data volumes")
@@ -102,6 +118,9 @@ func (cli *DockerCli) CmdComposeDown(args ...string) error {
 	if !imageType.Valid() {
 		return fmt.Errorf("rmi with %s is not valid", *rmi)
 	}
+	if err := checkComposeFiles(composeFiles.GetAll()); err != nil {
+		return err
+	}
 	if *projectName == "" {
 		*projectName = getBaseDir()
 	}
@@ -110,7 +129,13 @@ func (cli *DockerCli) CmdComposeDown(args ...string) error {
 		return err
 	}
 	defer body.Close()
-	return jsonmessage.DisplayJSONMessagesStream(body, cli.out, cli.outFd, cli.isTerminalOut, nil)
+	if err := jsonmessage.DisplayJSONMessagesStream(body, cli.out, cli.outFd, cli.isTerminalOut, nil); err != nil {
+		return err
+	}
+	if err := cli.downHyperServices(context.Background(), *projectName, cmd.Args()); err != nil {
+		return err
+	}
+	return cli.downComposeJobs(context.Background(), *projectName, cmd.Args())
 }
 
 // CmdComposeUp
@@ -128,20 +153,29 @@ func (cli *DockerCli) CmdComposeUp(args ...string) error {
 		"flag.\n\n"+
 		"If you want to force Compose to stop and recreate all containers, use the\n"+
 		"`--force-recreate` flag.", false)
-	composeFile := cmd.String([]string{"f", "-file"}, "docker-compose.yml", "Specify an alternate compose file")
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
 	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
 	detach := cmd.Bool([]string{"d", "-detach"}, false, "Detached mode: Run containers in the background,\nprint new container names.\nIncompatible with --abort-on-container-exit.")
 	forcerecreate := cmd.Bool([]string{"-force-recreate"}, false, "Recreate containers even if their configuration\nand image haven't changed.\nIncompatible with --no-recreate.")
 	norecreate := cmd.Bool([]string{"-no-recreate"}, false, "If containers already exist, don't recreate them.\nIncompatible with --force-recreate.")
+	yes := cmd.Bool([]string{"y", "-yes"}, false, "Allocate the missing floating IPs without asking")
+	noFipAllocate := cmd.Bool([]string{"-no-fip-allocate"}, false, "Fail instead of allocating missing floating IPs")
+	waitTimeout := cmd.Int([]string{"-wait-timeout"}, defaultComposeWaitTimeout, "Seconds to wait for the dependencies of a service\nto be healthy or completed")
+	build := cmd.Bool([]string{"-build"}, false, "Build images before starting containers")
 
 	cmd.Require(flag.Min, 0)
 	err := cmd.ParseFlags(args, true)
 	if err != nil {
 		return err
 	}
+	if *yes && *noFipAllocate {
+		return fmt.Errorf("--yes and --no-fip-allocate cannot be combined")
+	}
+	files := resolveComposeFiles(composeFiles.GetAll())
 	project, err := docker.NewProject(&docker.Context{
 		Context: project.Context{
-			ComposeFiles:  []string{*composeFile},
+			ComposeFiles:  files,
 			ProjectName:   *projectName,
 			LoggerFactory: logger.NewColorLoggerFactory(),
 		},
@@ -157,52 +191,31 @@ func (cli *DockerCli) CmdComposeUp(args ...string) error {
 	if *projectName == "" {
 		*projectName = getBaseDir()
 	}
-	var fips = []string{}
-	var newFipNum = 0
-	for _, svconfig := range c.M {
-		if svconfig.Fip == ComposeFipAuto {
-			newFipNum++
-		}
+	if err := cli.buildComposeImages(context.Background(), *projectName, c, nil, composeBuildOptions{force: *build}); err != nil {
+		return err
 	}
-	fipFilterArgs, _ := filters.FromParam("dangling=true")
-	options := types.NetworkListOptions{
-		Filters: fipFilterArgs,
+	if err := cli.assignComposeFips(context.Background(), *projectName, c, composeFipOptions{yes: *yes, noAllocate: *noFipAllocate}); err != nil {
+		return err
 	}
-	fipList, err := cli.client.FipList(context.Background(), options)
-	if err == nil {
-		for _, fip := range fipList {
-			if fip["container"] == "" && fip["service"] == "" {
-				fips = append(fips, fip["fip"])
-			}
-		}
+	requested := len(services)
+	hyperServices, services, err := extractHyperServices(c, services)
+	if err != nil {
+		return err
 	}
-	if newFipNum > len(fips) {
-		if askForConfirmation(warnMessage) == true {
-			newFips, err := cli.client.FipAllocate(context.Background(), fmt.Sprintf("%d", newFipNum-len(fips)))
-			if err != nil {
-				return err
-			}
-			fips = append(fips, newFips...)
-		}
+	if err := cli.upHyperServices(context.Background(), *projectName, hyperServices, composeProjectDir(files), *forcerecreate); err != nil {
+		return err
 	}
-	i := 0
-	for _, svconfig := range c.M {
-		if svconfig.Fip == ComposeFipAuto {
-			if i >= newFipNum {
-				svconfig.Fip = ""
-			} else {
-				svconfig.Fip = fips[i]
-				i++
-			}
+	if requested == 0 {
+		crons, funcs := project.GetHyperConfig()
+		if err := cli.upComposeJobs(context.Background(), *projectName, crons, funcs); err != nil {
+			return err
 		}
 	}
-	body, err := cli.client.ComposeUp(*projectName, services, c, vc, nc, cli.configFile.AuthConfigs, *forcerecreate, *norecreate)
-	if err != nil {
-		return err
+	if c.Len() == 0 || (requested > 0 && len(services) == 0) {
+		// only Hyper services were requested
+		return nil
 	}
-	defer body.Close()
-	err = jsonmessage.DisplayJSONMessagesStream(body, cli.out, cli.outFd, cli.isTerminalOut, nil)
-	if err != nil {
+	if err := cli.composeUp(context.Background(), *projectName, services, c, vc, nc, *forcerecreate, *norecreate, time.Duration(*waitTimeout)*time.Second); err != nil {
 		return err
 	}
 	if !*detach {
@@ -238,6 +251,8 @@ func (cli *DockerCli) CmdComposeUp(args ...string) error {
 // Usage: hyper compose start [OPTIONS] [SERVICE]
 func (cli *DockerCli) CmdComposeStart(args ...string) error {
 	cmd := Cli.Subcmd("compose start", []string{"[SERVICE...]"}, "Start existing containers.", false)
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
 	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
 	cmd.Require(flag.Min, 0)
 	err := cmd.ParseFlags(args, true)
@@ -245,6 +260,9 @@ func (cli *DockerCli) CmdComposeStart(args ...string) error {
 		return err
 	}
 	services := cmd.Args()
+	if err := checkComposeFiles(composeFiles.GetAll()); err != nil {
+		return err
+	}
 	if *projectName == "" {
 		*projectName = getBaseDir()
 	}
@@ -261,6 +279,8 @@ func (cli *DockerCli) CmdComposeStart(args ...string) error {
 // Usage: hyper compose stop [OPTIONS]
 func (cli *DockerCli) CmdComposeStop(args ...string) error {
 	cmd := Cli.Subcmd("compose stop", []string{"[SERVICE...]"}, "Stop running containers without removing them.\n\nThey can be started again with `hyper compose start`.", false)
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
 	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
 	nSeconds := cmd.Int([]string{"t", "-timeout"}, 10, "Specify a shutdown timeout in seconds.")
 	cmd.Require(flag.Min, 0)
@@ -270,6 +290,9 @@ func (cli *DockerCli) CmdComposeStop(args ...string) error {
 	}
 
 	services := cmd.Args()
+	if err := checkComposeFiles(composeFiles.GetAll()); err != nil {
+		return err
+	}
 	if *projectName == "" {
 		*projectName = getBaseDir()
 	}
@@ -286,7 +309,8 @@ func (cli *DockerCli) CmdComposeStop(args ...string) error {
 // Usage: hyper compose create [OPTIONS]
 func (cli *DockerCli) CmdComposeCreate(args ...string) error {
 	cmd := Cli.Subcmd("compose create", []string{"[SERVICE...]"}, "Creates containers for a service.", false)
-	composeFile := cmd.String([]string{"f", "-file"}, "docker-compose.yml", "Specify an alternate compose file")
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
 	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
 	forcerecreate := cmd.Bool([]string{"-force-recreate"}, false, "Recreate containers even if their configuration\nand image haven't changed.\nIncompatible with --no-recreate.")
 	norecreate := cmd.Bool([]string{"-no-recreate"}, false, "If containers already exist, don't recreate them.\nIncompatible with --force-recreate.")
@@ -295,9 +319,10 @@ func (cli *DockerCli) CmdComposeCreate(args ...string) error {
 	if err != nil {
 		return err
 	}
+	files := resolveComposeFiles(composeFiles.GetAll())
 	project, err := docker.NewProject(&docker.Context{
 		Context: project.Context{
-			ComposeFiles:  []string{*composeFile},
+			ComposeFiles:  files,
 			ProjectName:   *projectName,
 			LoggerFactory: logger.NewColorLoggerFactory(),
 		},
@@ -313,6 +338,9 @@ func (cli *DockerCli) CmdComposeCreate(args ...string) error {
 	if *projectName == "" {
 		*projectName = getBaseDir()
 	}
+	if err := cli.buildComposeImages(context.Background(), *projectName, c, nil, composeBuildOptions{}); err != nil {
+		return err
+	}
 	body, err := cli.client.ComposeCreate(*projectName, services, c, vc, nc, cli.configFile.AuthConfigs, *forcerecreate, *norecreate)
 	if err != nil {
 		return err
@@ -327,7 +355,8 @@ func (cli *DockerCli) CmdComposeCreate(args ...string) error {
 // Usage: hyper compose ps [OPTIONS]
 func (cli *DockerCli) CmdComposePs(args ...string) error {
 	cmd := Cli.Subcmd("compose ps", []string{"[SERVICE...]"}, "List containers.", false)
-	composeFile := cmd.String([]string{"f", "-file"}, "docker-compose.yml", "Specify an alternate compose file")
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
 	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
 	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Only display IDs")
 	cmd.Require(flag.Min, 0)
@@ -335,12 +364,13 @@ func (cli *DockerCli) CmdComposePs(args ...string) error {
 	if err != nil {
 		return err
 	}
+	files := resolveComposeFiles(composeFiles.GetAll())
 	if *projectName == "" {
 		*projectName = getBaseDir()
 	}
 	project, err := docker.NewProject(&docker.Context{
 		Context: project.Context{
-			ComposeFiles: []string{*composeFile},
+			ComposeFiles: files,
 			ProjectName:  *projectName,
 		},
 		ClientFactory: cli,
@@ -363,6 +393,8 @@ func (cli *DockerCli) CmdComposePs(args ...string) error {
 // Usage: hyper compose kill [OPTIONS]
 func (cli *DockerCli) CmdComposeKill(args ...string) error {
 	cmd := Cli.Subcmd("compose kill", []string{"[SERVICE...]"}, "Force stop service containers.", false)
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
 	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
 	signal := cmd.String([]string{}, "KILL", "Signal to send to the container")
 	cmd.Require(flag.Min, 0)
@@ -371,6 +403,9 @@ func (cli *DockerCli) CmdComposeKill(args ...string) error {
 		return err
 	}
 	services := cmd.Args()
+	if err := checkComposeFiles(composeFiles.GetAll()); err != nil {
+		return err
+	}
 	if *projectName == "" {
 		*projectName = getBaseDir()
 	}
@@ -387,6 +422,8 @@ func (cli *DockerCli) CmdComposeKill(args ...string) error {
 // Usage: hyper compose rm [OPTIONS] [SERVICE]
 func (cli *DockerCli) CmdComposeRm(args ...string) error {
 	cmd := Cli.Subcmd("compose rm", []string{"[SERVICE...]"}, "Remove stopped service containers.", false)
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
 	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
 	removeVol := cmd.Bool([]string{"v"}, false, "Remove volumes associated with containers")
 	cmd.Require(flag.Min, 0)
@@ -395,6 +432,9 @@ func (cli *DockerCli) CmdComposeRm(args ...string) error {
 		return err
 	}
 	services := cmd.Args()
+	if err := checkComposeFiles(composeFiles.GetAll()); err != nil {
+		return err
+	}
 	if *projectName == "" {
 		*projectName = getBaseDir()
 	}
@@ -411,7 +451,8 @@ func (cli *DockerCli) CmdComposeRm(args ...string) error {
 // Usage: hyper compose scale [OPTIONS] [SERVICE=NUM...]
 func (cli *DockerCli) CmdComposeScale(args ...string) error {
 	cmd := Cli.Subcmd("compose scale", []string{"[SERVICE=NUM...]"}, "Set number of containers to run for a service.", false)
-	composeFile := cmd.String([]string{"f", "-file"}, "docker-compose.yml", "Specify an alternate compose file")
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
 	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
 	timeout := cmd.Int([]string{"t", "-timeout"}, 10, "Specify a shutdown timeout in seconds")
 	cmd.Require(flag.Min, 0)
@@ -419,12 +460,13 @@ func (cli *DockerCli) CmdComposeScale(args ...string) error {
 	if err != nil {
 		return err
 	}
+	files := resolveComposeFiles(composeFiles.GetAll())
 	if *projectName == "" {
 		*projectName = getBaseDir()
 	}
 	project, err := docker.NewProject(&docker.Context{
 		Context: project.Context{
-			ComposeFiles: []string{*composeFile},
+			ComposeFiles: files,
 			ProjectName:  *projectName,
 		},
 		ClientFactory: cli,
@@ -458,15 +500,17 @@ func (cli *DockerCli) CmdComposeScale(args ...string) error {
 // Usage: hyper compose pull [OPTIONS]
 func (cli *DockerCli) CmdComposePull(args ...string) error {
 	cmd := Cli.Subcmd("compose pull", []string{"[SERVICE...]"}, "Pull images of services.", false)
-	composeFile := cmd.String([]string{"f", "-file"}, "docker-compose.yml", "Specify an alternate compose file")
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
 	cmd.Require(flag.Min, 0)
 	err := cmd.ParseFlags(args, true)
 	if err != nil {
 		return err
 	}
+	files := resolveComposeFiles(composeFiles.GetAll())
 	project, err := docker.NewProject(&docker.Context{
 		Context: project.Context{
-			ComposeFiles: []string{*composeFile},
+			ComposeFiles: files,
 		},
 		ClientFactory: cli,
 	})
@@ -481,18 +525,106 @@ func (cli *DockerCli) CmdComposePull(args ...string) error {
 	return nil
 }
 
+// composeConfig is the resolved project printed by `hyper compose config`.
+type composeConfig struct {
+	Version  string                           `yaml:"version"`
+	Services map[string]*config.ServiceConfig `yaml:"services"`
+	Volumes  map[string]*config.VolumeConfig  `yaml:"volumes,omitempty"`
+	Networks map[string]*config.NetworkConfig `yaml:"networks,omitempty"`
+	Crons    map[string]*config.CronConfig    `yaml:"crons,omitempty"`
+	Funcs    map[string]*config.FuncConfig    `yaml:"funcs,omitempty"`
+}
+
+// CmdComposeConfig validates the compose files and prints the resolved project.
+//
+// Usage: hyper compose config [OPTIONS]
+func (cli *DockerCli) CmdComposeConfig(args ...string) error {
+	cmd := Cli.Subcmd("compose config", []string{}, "Validate and view the compose file.\n\n"+
+		"The compose files are merged, interpolated and validated the same way as\n"+
+		"`hyper compose up` does, then the resulting configuration is printed.", false)
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
+	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
+	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Only validate the configuration, don't print anything")
+	services := cmd.Bool([]string{"-services"}, false, "Print the service names, one per line")
+	volumes := cmd.Bool([]string{"-volumes"}, false, "Print the volume names, one per line")
+	cmd.Require(flag.Exact, 0)
+	err := cmd.ParseFlags(args, true)
+	if err != nil {
+		return err
+	}
+	files := resolveComposeFiles(composeFiles.GetAll())
+	if *projectName == "" {
+		*projectName = getBaseDir()
+	}
+	project, err := docker.NewProject(&docker.Context{
+		Context: project.Context{
+			ComposeFiles: files,
+			ProjectName:  *projectName,
+		},
+		ClientFactory: cli,
+	})
+	if err != nil {
+		return err
+	}
+	if *quiet {
+		return nil
+	}
+
+	c, vc, nc := project.GetConfig()
+	if *services || *volumes {
+		var names []string
+		if *services {
+			names = c.Keys()
+		} else {
+			for name := range vc {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintln(cli.out, name)
+		}
+		return nil
+	}
+
+	crons, funcs := project.GetHyperConfig()
+	data, err := yaml.Marshal(composeConfig{
+		Version:  "2",
+		Services: c.M,
+		Volumes:  vc,
+		Networks: nc,
+		Crons:    crons,
+		Funcs:    funcs,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = cli.out.Write(data)
+	return err
+}
+
 func composeUsage() string {
 	composeCommands := [][]string{
+		{"build", "Build or rebuild services"},
+		{"config", "Validate and view the compose file"},
 		{"create", "Creates containers for a service"},
 		{"down", "Stop and remove containers, images, and volumes"},
+		{"exec", "Execute a command in a running container"},
+		{"images", "List images"},
 		{"kill", "Force stop service containers"},
+		{"logs", "View output from containers"},
+		{"ls", "List compose projects"},
+		{"port", "Print the public port for a port binding"},
 		{"ps", "List containers"},
 		{"pull", "Pull images of services"},
+		{"restart", "Restart services"},
 		{"rm", "Remove stopped service containers"},
 		{"run", "Run a one-off command"},
 		{"scale", "Set number of containers for a service"},
 		{"start", "Start services"},
 		{"stop", "Stop services"},
+		{"top", "Display the running processes"},
 		{"up", "Create and start containers"},
 	}
 
@@ -510,6 +642,66 @@ func (cli *DockerCli) Create(s project.Service) client.APIClient {
 	return cli.client
 }
 
+// resolveComposeFiles returns the compose files of the project, in merge
+// order. Files given with -f take precedence over the COMPOSE_FILE
+// environment variable, a list separated like PATH. When neither is set,
+// docker-compose.yml is used along with docker-compose.override.yml if
+// it exists.
+func resolveComposeFiles(flagFiles []string) []string {
+	if len(flagFiles) > 0 {
+		return flagFiles
+	}
+	if env := os.Getenv("COMPOSE_FILE"); env != "" {
+		var files []string
+		for _, f := range filepath.SplitList(env) {
+			if f != "" {
+				files = append(files, f)
+			}
+		}
+		if len(files) > 0 {
+			return files
+		}
+	}
+	files := []string{defaultComposeFile}
+	if _, err := os.Stat(defaultComposeOverrideFile); err == nil {
+		files = append(files, defaultComposeOverrideFile)
+	}
+	return files
+}
+
+// checkComposeFiles makes sure the compose files exist for the commands
+// only using the project name, so that -f is accepted the same way by all
+// the compose commands. The default files are optional for these commands.
+func checkComposeFiles(flagFiles []string) error {
+	if len(flagFiles) == 0 && os.Getenv("COMPOSE_FILE") == "" {
+		return nil
+	}
+	for _, f := range resolveComposeFiles(flagFiles) {
+		if f == "-" {
+			continue
+		}
+		if _, err := os.Stat(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// composeProjectDir returns the directory holding the first compose file,
+// relative paths of the project are resolved against it.
+func composeProjectDir(files []string) string {
+	if len(files) > 0 && files[0] != "-" {
+		if abs, err := filepath.Abs(files[0]); err == nil {
+			return filepath.Dir(abs)
+		}
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	return dir
+}
+
 func getBaseDir() string {
 	file, err := os.Getwd()
 	if err != nil {
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hyperhq/hyper-api/types"
+	Cli "github.com/hyperhq/hypercli/cli"
+	"github.com/hyperhq/hypercli/opts"
+	"github.com/hyperhq/hypercli/pkg/jsonmessage"
+	flag "github.com/hyperhq/hypercli/pkg/mflag"
+	"github.com/hyperhq/hypercli/pkg/progress"
+	"github.com/hyperhq/hypercli/pkg/streamformatter"
+	"github.com/hyperhq/hypercli/pkg/urlutil"
+	runconfigopts "github.com/hyperhq/hypercli/runconfig/opts"
+	"github.com/hyperhq/libcompose/config"
+	"github.com/hyperhq/libcompose/docker"
+	"github.com/hyperhq/libcompose/project"
+	"golang.org/x/net/context"
+)
+
+// composeBuildOptions controls how the images of the services with a build
+// section are built.
+type composeBuildOptions struct {
+	// force builds the images even if they already exist.
+	force   bool
+	noCache bool
+	pull    bool
+	forceRm bool
+}
+
+// composeBuildTag returns the image built for a service: its image if set,
+// <project>_<service> otherwise.
+func composeBuildTag(projectName, service string, sc *config.ServiceConfig) string {
+	if sc.Image != "" {
+		return sc.Image
+	}
+	return project.NormalizeName(projectName) + "_" + service
+}
+
+// buildComposeImages builds the images of the given services of c, or of
+// all its services if none is given, that have a build section. Images
+// that already exist are only rebuilt with opts.force. The services are
+// then set to run the image built for them.
+func (cli *DockerCli) buildComposeImages(ctx context.Context, projectName string, c *config.ServiceConfigs, services []string, opts composeBuildOptions) error {
+	selected := make(map[string]bool)
+	for _, name := range services {
+		selected[name] = true
+	}
+	var names []string
+	for name, sc := range c.M {
+		if sc.Build != nil && sc.Build.Context != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sc := c.M[name]
+		tag := composeBuildTag(projectName, name, sc)
+		sc.Image = tag
+		if len(selected) > 0 && !selected[name] {
+			continue
+		}
+		if !opts.force {
+			_, _, err := cli.client.ImageInspectWithRaw(ctx, tag, false)
+			if err == nil {
+				continue
+			}
+			if !strings.Contains(err.Error(), "No such image") {
+				return err
+			}
+		}
+		fmt.Fprintf(cli.out, "Building %s\n", name)
+		if err := cli.buildComposeImage(ctx, tag, sc.Build, opts); err != nil {
+			return fmt.Errorf("Service %s failed to build: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// buildComposeImage builds the image of a service from its build section
+// and tags it.
+func (cli *DockerCli) buildComposeImage(ctx context.Context, tag string, build *config.Build, opts composeBuildOptions) error {
+	dockerfile := build.Dockerfile
+	if dockerfile != "" && !filepath.IsAbs(dockerfile) && !urlutil.IsURL(build.Context) && !urlutil.IsGitURL(build.Context) {
+		// the Dockerfile of a compose file is relative to the context
+		dockerfile = filepath.Join(build.Context, dockerfile)
+	}
+	buildCtx, relDockerfile, cleanup, err := prepareBuildContext(nil, cli.out, build.Context, dockerfile)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	progressOutput := streamformatter.NewStreamFormatter().NewProgressOutput(cli.out, true)
+	var body io.Reader = progress.NewProgressReader(buildCtx, progressOutput, 0, "", "Sending build context to Hyper")
+
+	response, err := cli.client.ImageBuild(ctx, body, types.ImageBuildOptions{
+		Tags:        []string{tag},
+		Dockerfile:  relDockerfile,
+		BuildArgs:   runconfigopts.ConvertKVStringsToMap(build.Args),
+		NoCache:     opts.noCache,
+		PullParent:  opts.pull,
+		Remove:      true,
+		ForceRemove: opts.forceRm,
+		AuthConfigs: cli.configFile.AuthConfigs,
+	})
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	return jsonmessage.DisplayJSONMessagesStream(response.Body, cli.out, cli.outFd, cli.isTerminalOut, nil)
+}
+
+// CmdComposeBuild builds or rebuilds the images of the services with a
+// build section.
+//
+// Usage: hyper compose build [OPTIONS] [SERVICE...]
+func (cli *DockerCli) CmdComposeBuild(args ...string) error {
+	cmd := Cli.Subcmd("compose build", []string{"[SERVICE...]"}, "Build or rebuild services.\n\n"+
+		"Services are built once and then tagged as `project_service`, or with their\n"+
+		"image if set. If you change a service's Dockerfile or the contents of its\n"+
+		"build directory, run `hyper compose build` to rebuild it.", false)
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
+	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
+	noCache := cmd.Bool([]string{"-no-cache"}, false, "Do not use cache when building the image")
+	pull := cmd.Bool([]string{"-pull"}, false, "Always attempt to pull a newer version of the image")
+	forceRm := cmd.Bool([]string{"-force-rm"}, false, "Always remove intermediate containers")
+	cmd.Require(flag.Min, 0)
+	err := cmd.ParseFlags(args, true)
+	if err != nil {
+		return err
+	}
+	files := resolveComposeFiles(composeFiles.GetAll())
+	if *projectName == "" {
+		*projectName = getBaseDir()
+	}
+	project, err := docker.NewProject(&docker.Context{
+		Context: project.Context{
+			ComposeFiles: files,
+			ProjectName:  *projectName,
+		},
+		ClientFactory: cli,
+	})
+	if err != nil {
+		return err
+	}
+
+	c, _, _ := project.GetConfig()
+	for _, name := range cmd.Args() {
+		sc, ok := c.Get(name)
+		if !ok {
+			return fmt.Errorf("No such service: %s", name)
+		}
+		if sc.Build == nil {
+			fmt.Fprintf(cli.out, "%s uses an image, skipping\n", name)
+		}
+	}
+	return cli.buildComposeImages(context.Background(), *projectName, c, cmd.Args(), composeBuildOptions{
+		force:   true,
+		noCache: *noCache,
+		pull:    *pull,
+		forceRm: *forceRm,
+	})
+}
@@ -0,0 +1,324 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/hyper-api/types/filters"
+	Cli "github.com/hyperhq/hypercli/cli"
+	"github.com/hyperhq/hypercli/opts"
+	flag "github.com/hyperhq/hypercli/pkg/mflag"
+	"github.com/hyperhq/hypercli/pkg/stringid"
+	"github.com/hyperhq/hypercli/reference"
+	"github.com/hyperhq/libcompose/labels"
+	"github.com/hyperhq/libcompose/project"
+	"golang.org/x/net/context"
+)
+
+// composeContainerList sorts containers by service and container number.
+type composeContainerList []types.Container
+
+func (l composeContainerList) Len() int      { return len(l) }
+func (l composeContainerList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l composeContainerList) Less(i, j int) bool {
+	si, sj := l[i].Labels[labels.SERVICE.Str()], l[j].Labels[labels.SERVICE.Str()]
+	if si != sj {
+		return si < sj
+	}
+	return composeContainerNumber(l[i]) < composeContainerNumber(l[j])
+}
+
+func composeContainerNumber(c types.Container) int {
+	n, _ := strconv.Atoi(c.Labels[labels.NUMBER.Str()])
+	return n
+}
+
+// composeContainers returns the containers of the given services of a
+// compose project, or of all its services if none is given, sorted by
+// service and container number. Oneoff containers are excluded.
+func (cli *DockerCli) composeContainers(ctx context.Context, projectName string, services []string) ([]types.Container, error) {
+	args := filters.NewArgs()
+	args.Add("label", fmt.Sprintf("%s=%s", labels.PROJECT.Str(), project.NormalizeName(projectName)))
+	args.Add("label", fmt.Sprintf("%s=%s", labels.ONEOFF.Str(), "False"))
+	containers, err := cli.client.ContainerList(ctx, types.ContainerListOptions{All: true, Filter: args})
+	if err != nil {
+		return nil, err
+	}
+	var selected composeContainerList
+	for _, c := range containers {
+		if len(services) == 0 {
+			selected = append(selected, c)
+			continue
+		}
+		for _, s := range services {
+			if c.Labels[labels.SERVICE.Str()] == s {
+				selected = append(selected, c)
+				break
+			}
+		}
+	}
+	sort.Sort(selected)
+	return selected, nil
+}
+
+// composeServiceContainer returns the container number index of service.
+func (cli *DockerCli) composeServiceContainer(ctx context.Context, projectName, service string, index int) (types.Container, error) {
+	containers, err := cli.composeContainers(ctx, projectName, []string{service})
+	if err != nil {
+		return types.Container{}, err
+	}
+	if len(containers) == 0 {
+		return types.Container{}, fmt.Errorf("No container found for service %s", service)
+	}
+	for _, c := range containers {
+		if composeContainerNumber(c) == index {
+			return c, nil
+		}
+	}
+	return types.Container{}, fmt.Errorf("No container found for %s-%d", service, index)
+}
+
+func composeContainerName(c types.Container) string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return c.ID
+}
+
+// CmdComposeExec
+//
+// Usage: hyper compose exec [OPTIONS] SERVICE COMMAND [ARGS...]
+func (cli *DockerCli) CmdComposeExec(args ...string) error {
+	cmd := Cli.Subcmd("compose exec", []string{"SERVICE COMMAND [ARGS...]"}, "Execute a command in a running container of a service.\n\n"+
+		"By default a pseudo-TTY is allocated when the input is a terminal.", false)
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
+	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
+	detach := cmd.Bool([]string{"d", "-detach"}, false, "Detached mode: Run command in the background")
+	noTty := cmd.Bool([]string{"T"}, false, "Disable pseudo-tty allocation")
+	index := cmd.Int([]string{"-index"}, 1, "Index of the container if there are multiple\ninstances of a service")
+	cmd.Require(flag.Min, 2)
+	err := cmd.ParseFlags(args, true)
+	if err != nil {
+		return err
+	}
+	if err := checkComposeFiles(composeFiles.GetAll()); err != nil {
+		return err
+	}
+	if *projectName == "" {
+		*projectName = getBaseDir()
+	}
+
+	c, err := cli.composeServiceContainer(context.Background(), *projectName, cmd.Arg(0), *index)
+	if err != nil {
+		return err
+	}
+	var execArgs []string
+	switch {
+	case *detach:
+		execArgs = append(execArgs, "-d")
+	case *noTty || !cli.isTerminalIn:
+		execArgs = append(execArgs, "-i")
+	default:
+		execArgs = append(execArgs, "-i", "-t")
+	}
+	execArgs = append(execArgs, c.ID)
+	return cli.CmdExec(append(execArgs, cmd.Args()[1:]...)...)
+}
+
+// CmdComposeRestart
+//
+// Usage: hyper compose restart [OPTIONS] [SERVICE...]
+func (cli *DockerCli) CmdComposeRestart(args ...string) error {
+	cmd := Cli.Subcmd("compose restart", []string{"[SERVICE...]"}, "Restart running containers.", false)
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
+	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
+	nSeconds := cmd.Int([]string{"t", "-timeout"}, 10, "Specify a shutdown timeout in seconds.")
+	cmd.Require(flag.Min, 0)
+	err := cmd.ParseFlags(args, true)
+	if err != nil {
+		return err
+	}
+	if err := checkComposeFiles(composeFiles.GetAll()); err != nil {
+		return err
+	}
+	if *projectName == "" {
+		*projectName = getBaseDir()
+	}
+
+	ctx := context.Background()
+	containers, err := cli.composeContainers(ctx, *projectName, cmd.Args())
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, c := range containers {
+		name := composeContainerName(c)
+		if err := cli.client.ContainerRestart(ctx, c.ID, *nSeconds); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		} else {
+			fmt.Fprintf(cli.out, "Restarting %s ... done\n", name)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// CmdComposeTop
+//
+// Usage: hyper compose top [OPTIONS] [SERVICE...]
+func (cli *DockerCli) CmdComposeTop(args ...string) error {
+	cmd := Cli.Subcmd("compose top", []string{"[SERVICE...]"}, "Display the running processes.", false)
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
+	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
+	cmd.Require(flag.Min, 0)
+	err := cmd.ParseFlags(args, true)
+	if err != nil {
+		return err
+	}
+	if err := checkComposeFiles(composeFiles.GetAll()); err != nil {
+		return err
+	}
+	if *projectName == "" {
+		*projectName = getBaseDir()
+	}
+
+	ctx := context.Background()
+	containers, err := cli.composeContainers(ctx, *projectName, cmd.Args())
+	if err != nil {
+		return err
+	}
+	printed := false
+	for _, c := range containers {
+		if c.State != "running" {
+			continue
+		}
+		procList, err := cli.client.ContainerTop(ctx, c.ID, nil)
+		if err != nil {
+			return err
+		}
+		if printed {
+			fmt.Fprintln(cli.out)
+		}
+		printed = true
+		fmt.Fprintln(cli.out, composeContainerName(c))
+		w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
+		fmt.Fprintln(w, strings.Join(procList.Titles, "\t"))
+		for _, proc := range procList.Processes {
+			fmt.Fprintln(w, strings.Join(proc, "\t"))
+		}
+		w.Flush()
+	}
+	return nil
+}
+
+// CmdComposePort
+//
+// Usage: hyper compose port [OPTIONS] SERVICE PRIVATE_PORT
+func (cli *DockerCli) CmdComposePort(args ...string) error {
+	cmd := Cli.Subcmd("compose port", []string{"SERVICE PRIVATE_PORT"}, "Print the public port for a port binding.", false)
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
+	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
+	protocol := cmd.String([]string{"-protocol"}, "tcp", "tcp or udp")
+	index := cmd.Int([]string{"-index"}, 1, "Index of the container if there are multiple\ninstances of a service")
+	cmd.Require(flag.Exact, 2)
+	err := cmd.ParseFlags(args, true)
+	if err != nil {
+		return err
+	}
+	if err := checkComposeFiles(composeFiles.GetAll()); err != nil {
+		return err
+	}
+	if *projectName == "" {
+		*projectName = getBaseDir()
+	}
+
+	ctx := context.Background()
+	c, err := cli.composeServiceContainer(ctx, *projectName, cmd.Arg(0), *index)
+	if err != nil {
+		return err
+	}
+	info, err := cli.client.ContainerInspect(ctx, c.ID)
+	if err != nil {
+		return err
+	}
+	port, err := nat.NewPort(*protocol, cmd.Arg(1))
+	if err != nil {
+		return err
+	}
+	if frontends, exists := info.NetworkSettings.Ports[port]; exists && frontends != nil {
+		for _, frontend := range frontends {
+			fmt.Fprintf(cli.out, "%s:%s\n", frontend.HostIP, frontend.HostPort)
+		}
+		return nil
+	}
+	return fmt.Errorf("Error: No public port '%s' published for %s", port, composeContainerName(c))
+}
+
+// CmdComposeImages
+//
+// Usage: hyper compose images [OPTIONS] [SERVICE...]
+func (cli *DockerCli) CmdComposeImages(args ...string) error {
+	cmd := Cli.Subcmd("compose images", []string{"[SERVICE...]"}, "List images used by the created containers.", false)
+	composeFiles := opts.NewListOpts(nil)
+	cmd.Var(&composeFiles, []string{"f", "-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
+	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
+	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Only display image IDs")
+	cmd.Require(flag.Min, 0)
+	err := cmd.ParseFlags(args, true)
+	if err != nil {
+		return err
+	}
+	if err := checkComposeFiles(composeFiles.GetAll()); err != nil {
+		return err
+	}
+	if *projectName == "" {
+		*projectName = getBaseDir()
+	}
+
+	ctx := context.Background()
+	containers, err := cli.composeContainers(ctx, *projectName, cmd.Args())
+	if err != nil {
+		return err
+	}
+	if *quiet {
+		seen := make(map[string]bool)
+		for _, c := range containers {
+			if !seen[c.ImageID] {
+				seen[c.ImageID] = true
+				fmt.Fprintln(cli.out, c.ImageID)
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tREPOSITORY\tTAG\tIMAGE ID\tSIZE")
+	for _, c := range containers {
+		repo, tag := c.Image, "<none>"
+		if ref, err := reference.ParseNamed(c.Image); err == nil {
+			repo = ref.Name()
+			if tagged, ok := reference.WithDefaultTag(ref).(reference.NamedTagged); ok {
+				tag = tagged.Tag()
+			}
+		}
+		size := "-"
+		if img, _, err := cli.client.ImageInspectWithRaw(ctx, c.ImageID, false); err == nil {
+			size = units.HumanSize(float64(img.Size))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", composeContainerName(c), repo, tag, stringid.TruncateID(c.ImageID), size)
+	}
+	w.Flush()
+	return nil
+}
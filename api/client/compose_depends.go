@@ -0,0 +1,347 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/hypercli/api/client/formatter"
+	"github.com/hyperhq/hypercli/pkg/jsonmessage"
+	"github.com/hyperhq/libcompose/config"
+	"golang.org/x/net/context"
+)
+
+// Conditions a service can wait for on the services it depends on.
+const (
+	composeConditionStarted   = "service_started"
+	composeConditionHealthy   = "service_healthy"
+	composeConditionCompleted = "service_completed_successfully"
+)
+
+// Defaults of the healthcheck settings, the same as Docker.
+const (
+	defaultHealthCheckInterval = 30 * time.Second
+	defaultHealthCheckTimeout  = 30 * time.Second
+	defaultHealthCheckRetries  = 3
+)
+
+const (
+	defaultComposeWaitTimeout = 300
+	// composeDependencyPollInterval is how often containers are inspected
+	// while waiting for them to exit.
+	composeDependencyPollInterval = time.Second
+)
+
+// composeHealthCheck is a parsed healthcheck block.
+type composeHealthCheck struct {
+	// cmd is run in the container, the check passes if it exits with 0.
+	cmd []string
+	// port must accept TCP connections for the check to pass.
+	port        int
+	interval    time.Duration
+	timeout     time.Duration
+	startPeriod time.Duration
+	retries     int
+}
+
+// parseComposeHealthCheck converts the healthcheck of a service. It returns
+// nil if the service has no healthcheck or if it is disabled.
+func parseComposeHealthCheck(hc *config.HealthCheck) (*composeHealthCheck, error) {
+	if hc == nil || hc.Disable {
+		return nil, nil
+	}
+	check := &composeHealthCheck{
+		port:     hc.Port,
+		interval: defaultHealthCheckInterval,
+		timeout:  defaultHealthCheckTimeout,
+		retries:  defaultHealthCheckRetries,
+	}
+	test := []string(hc.Test)
+	switch {
+	case len(test) == 0:
+	case test[0] == "NONE":
+		return nil, nil
+	case test[0] == "CMD":
+		check.cmd = test[1:]
+	case test[0] == "CMD-SHELL":
+		check.cmd = []string{"/bin/sh", "-c", strings.Join(test[1:], " ")}
+	case len(test) == 1:
+		// a plain string is run by the shell
+		check.cmd = []string{"/bin/sh", "-c", test[0]}
+	default:
+		check.cmd = test
+	}
+	if len(check.cmd) == 0 && check.port == 0 {
+		return nil, nil
+	}
+
+	for _, d := range []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"interval", hc.Interval, &check.interval},
+		{"timeout", hc.Timeout, &check.timeout},
+		{"start_period", hc.StartPeriod, &check.startPeriod},
+	} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("Invalid healthcheck %s %q", d.name, d.value)
+		}
+		*d.dest = v
+	}
+	if hc.Retries > 0 {
+		check.retries = hc.Retries
+	}
+	return check, nil
+}
+
+// composeHasConditions reports whether a service of c waits for another one
+// to be healthy or to have completed.
+func composeHasConditions(c *config.ServiceConfigs) bool {
+	for _, sc := range c.M {
+		for _, condition := range sc.DependsOn.Conditions() {
+			if condition != composeConditionStarted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// composeStartOrder groups services, or all the services of c if none is
+// given, in levels where each service only depends on services of the
+// previous levels. Services are sorted by name within a level.
+func composeStartOrder(c *config.ServiceConfigs, services []string) ([][]string, error) {
+	if len(services) == 0 {
+		services = c.Keys()
+	}
+	pending := make(map[string]bool)
+	for _, name := range services {
+		pending[name] = true
+	}
+
+	var levels [][]string
+	for len(pending) > 0 {
+		var level []string
+		for name := range pending {
+			ready := true
+			if sc, ok := c.Get(name); ok {
+				for _, dep := range sc.DependsOn.Services() {
+					if pending[dep] {
+						ready = false
+						break
+					}
+				}
+			}
+			if ready {
+				level = append(level, name)
+			}
+		}
+		if len(level) == 0 {
+			var cycle []string
+			for name := range pending {
+				cycle = append(cycle, name)
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("Cycle detected in depends_on between %s", strings.Join(cycle, ", "))
+		}
+		sort.Strings(level)
+		for _, name := range level {
+			delete(pending, name)
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// composeUp creates and starts services, or all the services of c if none
+// is given. When services depend on others being healthy or completed,
+// they are started level by level, waiting for the conditions in between.
+func (cli *DockerCli) composeUp(ctx context.Context, projectName string, services []string, c *config.ServiceConfigs, vc map[string]*config.VolumeConfig, nc map[string]*config.NetworkConfig, forceRecreate, noRecreate bool, waitTimeout time.Duration) error {
+	if !composeHasConditions(c) {
+		return cli.composeUpServices(projectName, services, c, vc, nc, forceRecreate, noRecreate)
+	}
+	levels, err := composeStartOrder(c, services)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(waitTimeout)
+	waited := make(map[string]bool)
+	for _, level := range levels {
+		for _, name := range level {
+			sc, _ := c.Get(name)
+			conditions := sc.DependsOn.Conditions()
+			for _, dep := range sc.DependsOn.Services() {
+				condition := conditions[dep]
+				if condition == composeConditionStarted || waited[dep+":"+condition] {
+					continue
+				}
+				depConfig, ok := c.Get(dep)
+				if !ok {
+					continue
+				}
+				if err := cli.waitForComposeDependency(ctx, projectName, dep, condition, depConfig, deadline, waitTimeout); err != nil {
+					return err
+				}
+				waited[dep+":"+condition] = true
+			}
+		}
+		if err := cli.composeUpServices(projectName, level, c, vc, nc, forceRecreate, noRecreate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cli *DockerCli) composeUpServices(projectName string, services []string, c *config.ServiceConfigs, vc map[string]*config.VolumeConfig, nc map[string]*config.NetworkConfig, forceRecreate, noRecreate bool) error {
+	body, err := cli.client.ComposeUp(projectName, services, c, vc, nc, cli.configFile.AuthConfigs, forceRecreate, noRecreate)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return jsonmessage.DisplayJSONMessagesStream(body, cli.out, cli.outFd, cli.isTerminalOut, nil)
+}
+
+// waitForComposeDependency waits until the containers of service meet
+// condition, or fails once deadline is passed.
+func (cli *DockerCli) waitForComposeDependency(ctx context.Context, projectName, service, condition string, sc *config.ServiceConfig, deadline time.Time, waitTimeout time.Duration) error {
+	var check *composeHealthCheck
+	if condition == composeConditionHealthy {
+		var err error
+		if check, err = parseComposeHealthCheck(sc.HealthCheck); err != nil {
+			return fmt.Errorf("Service %s: %v", service, err)
+		}
+		if check == nil {
+			return fmt.Errorf("Service %s has no healthcheck, it can't be waited on to be healthy", service)
+		}
+	}
+
+	state := strings.TrimPrefix(condition, "service_")
+	state = strings.Replace(state, "_", " ", -1)
+	fmt.Fprintf(cli.out, "Waiting for %s to be %s\n", service, state)
+
+	started := time.Now()
+	failures := 0
+	for {
+		containers, err := cli.composeContainers(ctx, projectName, []string{service})
+		if err != nil {
+			return err
+		}
+		if len(containers) == 0 {
+			return fmt.Errorf("No container found for service %s", service)
+		}
+		var done bool
+		var probeErr error
+		if check != nil {
+			probeErr = cli.probeComposeContainers(ctx, containers, check)
+			done = probeErr == nil
+		} else {
+			if done, err = cli.composeContainersCompleted(ctx, service, containers); err != nil {
+				return err
+			}
+		}
+		if done {
+			return nil
+		}
+
+		interval := composeDependencyPollInterval
+		if check != nil {
+			interval = check.interval
+			if time.Since(started) >= check.startPeriod {
+				failures++
+				if failures >= check.retries {
+					return fmt.Errorf("Service %s is unhealthy: %v", service, probeErr)
+				}
+			}
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("Timed out after %s waiting for service %s to be %s", waitTimeout, service, state)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// composeContainersCompleted reports whether all the containers exited
+// successfully. It fails as soon as one exited with another code.
+func (cli *DockerCli) composeContainersCompleted(ctx context.Context, service string, containers []types.Container) (bool, error) {
+	for _, c := range containers {
+		info, err := cli.client.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			return false, err
+		}
+		if info.State.Running || info.State.Restarting {
+			return false, nil
+		}
+		if info.State.ExitCode != 0 {
+			return false, fmt.Errorf("Service %s didn't complete successfully: %s exited with code %d", service, composeContainerName(c), info.State.ExitCode)
+		}
+	}
+	return true, nil
+}
+
+// probeComposeContainers runs the healthcheck on each of the containers.
+func (cli *DockerCli) probeComposeContainers(ctx context.Context, containers []types.Container, check *composeHealthCheck) error {
+	for _, c := range containers {
+		if c.State != "running" {
+			return fmt.Errorf("%s is not running", composeContainerName(c))
+		}
+		if check.port != 0 {
+			if err := cli.probeComposePort(ctx, c, check); err != nil {
+				return fmt.Errorf("%s: %v", composeContainerName(c), err)
+			}
+		}
+		if len(check.cmd) > 0 {
+			if err := cli.probeComposeExec(ctx, c.ID, check.cmd, check.timeout); err != nil {
+				return fmt.Errorf("%s: %v", composeContainerName(c), err)
+			}
+		}
+	}
+	return nil
+}
+
+// probeComposePort checks that the port of a container accepts connections.
+// Containers with a floating IP are dialed directly, the others are checked
+// from inside the container with nc.
+func (cli *DockerCli) probeComposePort(ctx context.Context, c types.Container, check *composeHealthCheck) error {
+	port := strconv.Itoa(check.port)
+	if ip := c.Labels[formatter.FipLabel]; ip != "" {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, port), check.timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	return cli.probeComposeExec(ctx, c.ID, []string{"nc", "-z", "127.0.0.1", port}, check.timeout)
+}
+
+// probeComposeExec runs cmd in a container and fails unless it exits with
+// 0 within timeout.
+func (cli *DockerCli) probeComposeExec(ctx context.Context, containerID string, cmd []string, timeout time.Duration) error {
+	execID, err := cli.ExecCmd(ctx, "", containerID, cmd)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		running, status, err := getExecExitCode(ctx, cli, execID)
+		switch {
+		case err != nil:
+			return err
+		case !running && status == 0:
+			return nil
+		case !running:
+			return fmt.Errorf("%s exited with code %d", strings.Join(cmd, " "), status)
+		case time.Now().After(deadline):
+			return fmt.Errorf("%s timed out after %s", strings.Join(cmd, " "), timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,266 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/libcompose/config"
+	"github.com/hyperhq/libcompose/labels"
+	"golang.org/x/net/context"
+)
+
+// ComposeFipPoolPrefix prefixes the name of the pool to take the floating
+// IP of a service from, as in `fip: pool:frontend`. A pool is the set of
+// floating IPs with that name.
+const ComposeFipPoolPrefix = "pool:"
+
+// composeFipOptions controls how `compose up` gets the floating IPs of the
+// services with `fip: auto` or `fip: pool:<name>`.
+type composeFipOptions struct {
+	// yes allocates missing floating IPs without asking.
+	yes bool
+	// noAllocate fails instead of allocating missing floating IPs.
+	noAllocate bool
+}
+
+// parseComposeFip returns the pool the floating IP of a service is taken
+// from, "" for any dangling floating IP, and whether the service needs one
+// picked at all. Services without fip or with an explicit IP don't.
+func parseComposeFip(fip string) (string, bool, error) {
+	switch {
+	case fip == ComposeFipAuto:
+		return "", true, nil
+	case strings.HasPrefix(fip, ComposeFipPoolPrefix):
+		pool := strings.TrimPrefix(fip, ComposeFipPoolPrefix)
+		if pool == "" {
+			return "", false, fmt.Errorf("Invalid fip %q: missing pool name", fip)
+		}
+		return pool, true, nil
+	}
+	return "", false, nil
+}
+
+// planComposeFips assigns floating IPs to the services in requests, which
+// maps them to their pool. Services keep the IP in kept, the others take
+// one of the dangling floating IPs, pool services first and in name order
+// so that the result doesn't depend on map ordering. The services left
+// without IP are returned by pool.
+func planComposeFips(requests, kept map[string]string, dangling []map[string]string) (map[string]string, map[string][]string) {
+	assigned := make(map[string]string)
+	taken := make(map[string]bool)
+	for service, ip := range kept {
+		if _, ok := requests[service]; ok {
+			assigned[service] = ip
+			taken[ip] = true
+		}
+	}
+
+	var free []map[string]string
+	for _, fip := range dangling {
+		if !taken[fip["fip"]] {
+			free = append(free, fip)
+		}
+	}
+	sort.Sort(fipList(free))
+
+	var services []string
+	for service := range requests {
+		if _, ok := assigned[service]; !ok {
+			services = append(services, service)
+		}
+	}
+	sort.Sort(composeFipRequests{services, requests})
+
+	missing := make(map[string][]string)
+	for _, service := range services {
+		pool := requests[service]
+		found := false
+		for i, fip := range free {
+			if pool == "" || fip["name"] == pool {
+				assigned[service] = fip["fip"]
+				free = append(free[:i], free[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing[pool] = append(missing[pool], service)
+		}
+	}
+	return assigned, missing
+}
+
+// fipList sorts floating IPs by address.
+type fipList []map[string]string
+
+func (l fipList) Len() int           { return len(l) }
+func (l fipList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l fipList) Less(i, j int) bool { return l[i]["fip"] < l[j]["fip"] }
+
+// composeFipRequests sorts services taking their IP from a pool first,
+// then by name.
+type composeFipRequests struct {
+	services []string
+	pools    map[string]string
+}
+
+func (r composeFipRequests) Len() int { return len(r.services) }
+func (r composeFipRequests) Swap(i, j int) {
+	r.services[i], r.services[j] = r.services[j], r.services[i]
+}
+func (r composeFipRequests) Less(i, j int) bool {
+	pi, pj := r.pools[r.services[i]] != "", r.pools[r.services[j]] != ""
+	if pi != pj {
+		return pi
+	}
+	return r.services[i] < r.services[j]
+}
+
+// composeFipsInUse returns the floating IPs recorded on the containers and
+// Hyper services of a compose project, by service, along with the names
+// and IDs of the containers and services holding them.
+func (cli *DockerCli) composeFipsInUse(ctx context.Context, projectName string) (map[string]string, map[string]bool, error) {
+	previous := make(map[string]string)
+	owners := make(map[string]bool)
+	containers, err := cli.composeContainers(ctx, projectName, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range containers {
+		owners[c.ID] = true
+		owners[composeContainerName(c)] = true
+		if ip := c.Labels[labels.FIP.Str()]; ip != "" {
+			previous[c.Labels[labels.SERVICE.Str()]] = ip
+		}
+	}
+	services, err := cli.composeHyperServices(ctx, projectName)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, sv := range services {
+		owners[sv.Name] = true
+		if sv.FIP != "" {
+			previous[name] = sv.FIP
+		}
+	}
+	return previous, owners, nil
+}
+
+// assignComposeFips replaces `fip: auto` and `fip: pool:<name>` of the
+// services in c with actual floating IPs. A service keeps the IP it got on
+// a previous run as long as the project still holds it or it is dangling.
+// The IP is recorded in the labels of the service. Missing floating IPs
+// are allocated, pool ones are named after their pool.
+func (cli *DockerCli) assignComposeFips(ctx context.Context, projectName string, c *config.ServiceConfigs, opts composeFipOptions) error {
+	requests := make(map[string]string)
+	for name, sc := range c.M {
+		pool, ok, err := parseComposeFip(sc.Fip)
+		if err != nil {
+			return fmt.Errorf("Service %s: %v", name, err)
+		}
+		if ok {
+			requests[name] = pool
+		}
+	}
+	if len(requests) == 0 {
+		return nil
+	}
+
+	previous, owners, err := cli.composeFipsInUse(ctx, projectName)
+	if err != nil {
+		return err
+	}
+	all, err := cli.client.FipList(ctx, types.NetworkListOptions{})
+	if err != nil {
+		return err
+	}
+	var dangling []map[string]string
+	kept := make(map[string]string)
+	byIP := make(map[string]map[string]string)
+	for _, fip := range all {
+		byIP[fip["fip"]] = fip
+		if fip["container"] == "" && fip["service"] == "" {
+			dangling = append(dangling, fip)
+		}
+	}
+	for service, ip := range previous {
+		pool, ok := requests[service]
+		fip, exists := byIP[ip]
+		if !ok || !exists || (pool != "" && fip["name"] != pool) {
+			continue
+		}
+		if (fip["container"] == "" && fip["service"] == "") || owners[fip["container"]] || owners[fip["service"]] {
+			kept[service] = ip
+		}
+	}
+
+	assigned, missing := planComposeFips(requests, kept, dangling)
+	if len(missing) > 0 {
+		if err := cli.allocateComposeFips(ctx, missing, assigned, opts); err != nil {
+			return err
+		}
+	}
+
+	for name, ip := range assigned {
+		sc := c.M[name]
+		sc.Fip = ip
+		if sc.Labels == nil {
+			sc.Labels = make(map[string]string)
+		}
+		sc.Labels[labels.FIP.Str()] = ip
+	}
+	return nil
+}
+
+// allocateComposeFips allocates a floating IP for each of the services in
+// missing and adds them to assigned. Since floating IPs are billed, it
+// asks first unless opts.yes is set, and it never asks when the input is
+// not a terminal.
+func (cli *DockerCli) allocateComposeFips(ctx context.Context, missing map[string][]string, assigned map[string]string, opts composeFipOptions) error {
+	var pools, services []string
+	for pool, names := range missing {
+		pools = append(pools, pool)
+		services = append(services, names...)
+	}
+	sort.Strings(pools)
+	sort.Strings(services)
+
+	count := len(services)
+	switch {
+	case opts.noAllocate:
+		return fmt.Errorf("Not enough floating IPs for %s, %d more needed (--no-fip-allocate is set)", strings.Join(services, ", "), count)
+	case opts.yes:
+	case !cli.isTerminalIn:
+		return fmt.Errorf("Not enough floating IPs for %s, %d more needed: use --yes to allocate them", strings.Join(services, ", "), count)
+	default:
+		fmt.Fprintf(cli.out, "%d new floating IP(s) will be allocated for %s.\n", count, strings.Join(services, ", "))
+		if !askForConfirmation(warnMessage) {
+			return fmt.Errorf("Floating IP allocation declined, services %s have no floating IP", strings.Join(services, ", "))
+		}
+	}
+
+	ips, err := cli.client.FipAllocate(ctx, strconv.Itoa(count))
+	if err != nil {
+		return err
+	}
+	if len(ips) < count {
+		return fmt.Errorf("Server allocated %d floating IPs, %d requested", len(ips), count)
+	}
+	for _, pool := range pools {
+		for _, name := range missing[pool] {
+			ip := ips[0]
+			ips = ips[1:]
+			if pool != "" {
+				if err := cli.client.FipName(ctx, ip, pool); err != nil {
+					return err
+				}
+			}
+			fmt.Fprintf(cli.out, "Allocated floating IP %s for %s\n", ip, name)
+			assigned[name] = ip
+		}
+	}
+	return nil
+}
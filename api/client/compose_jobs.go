@@ -0,0 +1,354 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/hyper-api/types/container"
+	"github.com/hyperhq/hyper-api/types/network"
+	"github.com/hyperhq/hyper-api/types/strslice"
+	"github.com/hyperhq/hypercli/pkg/signal"
+	"github.com/hyperhq/hypercli/runconfig/opts"
+	"github.com/hyperhq/libcompose/config"
+	"github.com/hyperhq/libcompose/labels"
+	"github.com/hyperhq/libcompose/project"
+	"golang.org/x/net/context"
+)
+
+// Defaults of the crons and funcs of a compose project, the same as
+// `hyper cron create` and `hyper func create`.
+const (
+	defaultComposeJobSize     = "s4"
+	defaultComposeFuncTimeout = 300
+	defaultComposeMailPolicy  = "on-failure"
+)
+
+// composeJobName returns the name of the cron or func created for an entry
+// of the crons or funcs section of a compose project.
+func composeJobName(projectName, name string) string {
+	return project.NormalizeName(projectName) + "-" + name
+}
+
+// composeConfigHash returns the hash of a cron or func configuration,
+// stored in its labels to detect changes on the next `compose up`.
+func composeConfigHash(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// composeJobLabels returns the labels of the containers of a cron or func,
+// with the Hyper specific settings and the project labels added.
+func composeJobLabels(projectName, name string, securityGroups []string, noAutoVolume bool, userLabels map[string]string, hash string) map[string]string {
+	l := make(map[string]string)
+	for k, v := range userLabels {
+		l[k] = v
+	}
+	for _, sg := range securityGroups {
+		if sg != "" {
+			l[fmt.Sprintf("sh_hyper_sg_%s", sg)] = "yes"
+		}
+	}
+	if noAutoVolume {
+		l["sh_hyper_noauto_volume"] = "true"
+	}
+	l[labels.PROJECT.Str()] = project.NormalizeName(projectName)
+	l[labels.SERVICE.Str()] = name
+	l[labels.HASH.Str()] = hash
+	return l
+}
+
+// cronFromCompose converts an entry of the crons section into the cron job
+// to create.
+func cronFromCompose(projectName, name string, cc *config.CronConfig) (types.Cron, error) {
+	if cc.Schedule == "" {
+		return types.Cron{}, fmt.Errorf("Cron %s: schedule is required", name)
+	}
+	if cc.Image == "" {
+		return types.Cron{}, fmt.Errorf("Cron %s: image is required", name)
+	}
+	hash, err := composeConfigHash(cc)
+	if err != nil {
+		return types.Cron{}, err
+	}
+	size := cc.Size
+	if size == "" {
+		size = defaultComposeJobSize
+	}
+	l := composeJobLabels(projectName, name, cc.SecurityGroups, cc.NoAutoVolume, cc.Labels, hash)
+	l["sh_hyper_instancetype"] = size
+
+	var binds []string
+	volumes := make(map[string]struct{})
+	for _, v := range cc.Volumes {
+		if arr := opts.VolumeSplitN(v, 2); len(arr) > 1 {
+			binds = append(binds, v)
+		} else {
+			volumes[v] = struct{}{}
+		}
+	}
+	mailPolicy := cc.MailPolicy
+	if mailPolicy == "" {
+		mailPolicy = defaultComposeMailPolicy
+	}
+
+	return types.Cron{
+		Name:          composeJobName(projectName, name),
+		Schedule:      cc.Schedule,
+		ContainerName: cc.ContainerName,
+		OwnerEmail:    cc.MailTo,
+		MailPolicy:    mailPolicy,
+		Config: &container.Config{
+			Tty:        true,
+			Env:        []string(cc.Environment),
+			Cmd:        strslice.StrSlice(cc.Command),
+			Image:      cc.Image,
+			Volumes:    volumes,
+			Entrypoint: strslice.StrSlice(cc.Entrypoint),
+			WorkingDir: cc.WorkingDir,
+			Labels:     l,
+			StopSignal: signal.DefaultStopSignal,
+		},
+		HostConfig: &container.HostConfig{
+			Binds:       binds,
+			NetworkMode: container.NetworkMode("bridge"),
+		},
+		NetConfig: &network.NetworkingConfig{
+			EndpointsConfig: make(map[string]*network.EndpointSettings),
+		},
+	}, nil
+}
+
+// funcFromCompose converts an entry of the funcs section into the func to
+// create.
+func funcFromCompose(projectName, name string, fc *config.FuncConfig) (types.Func, error) {
+	if fc.Image == "" {
+		return types.Func{}, fmt.Errorf("Func %s: image is required", name)
+	}
+	hash, err := composeConfigHash(fc)
+	if err != nil {
+		return types.Func{}, err
+	}
+	fn := types.Func{
+		Name:          composeJobName(projectName, name),
+		ContainerSize: fc.Size,
+		Timeout:       fc.Timeout,
+		Config: types.FuncConfig{
+			Tty:        fc.Tty,
+			Cmd:        strslice.StrSlice(fc.Command),
+			Image:      fc.Image,
+			Entrypoint: strslice.StrSlice(fc.Entrypoint),
+			WorkingDir: fc.WorkingDir,
+			Labels:     composeJobLabels(projectName, name, fc.SecurityGroups, fc.NoAutoVolume, fc.Labels, hash),
+			StopSignal: signal.DefaultStopSignal,
+		},
+		HostConfig: types.FuncHostConfig{
+			NetworkMode: container.NetworkMode("bridge"),
+		},
+		NetworkingConfig: network.NetworkingConfig{
+			EndpointsConfig: make(map[string]*network.EndpointSettings),
+		},
+	}
+	env := []string(fc.Environment)
+	fn.Config.Env = &env
+	if fn.ContainerSize == "" {
+		fn.ContainerSize = defaultComposeJobSize
+	}
+	if fn.Timeout == 0 {
+		fn.Timeout = defaultComposeFuncTimeout
+	}
+	return fn, nil
+}
+
+// composeCrons returns the cron jobs of a compose project, by name in the
+// crons section.
+func (cli *DockerCli) composeCrons(ctx context.Context, projectName string) (map[string]types.Cron, error) {
+	list, err := cli.client.CronList(ctx, types.CronListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	crons := make(map[string]types.Cron)
+	for _, c := range list {
+		if c.Config != nil && c.Config.Labels[labels.PROJECT.Str()] == project.NormalizeName(projectName) {
+			crons[c.Config.Labels[labels.SERVICE.Str()]] = c
+		}
+	}
+	return crons, nil
+}
+
+// composeFuncs returns the funcs of a compose project, by name in the funcs
+// section.
+func (cli *DockerCli) composeFuncs(ctx context.Context, projectName string) (map[string]types.Func, error) {
+	list, err := cli.client.FuncList(ctx, types.FuncListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	funcs := make(map[string]types.Func)
+	for _, fn := range list {
+		if fn.Config.Labels[labels.PROJECT.Str()] == project.NormalizeName(projectName) {
+			funcs[fn.Config.Labels[labels.SERVICE.Str()]] = fn
+		}
+	}
+	return funcs, nil
+}
+
+// upComposeJobs creates the crons and funcs of a compose project, and
+// removes those no longer declared. Changed funcs are updated in place,
+// changed crons are recreated since they can't be updated.
+func (cli *DockerCli) upComposeJobs(ctx context.Context, projectName string, crons map[string]*config.CronConfig, funcs map[string]*config.FuncConfig) error {
+	existingCrons, err := cli.composeCrons(ctx, projectName)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(crons))
+	for name := range crons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		wanted, err := cronFromCompose(projectName, name, crons[name])
+		if err != nil {
+			return err
+		}
+		if err := cli.upComposeCron(ctx, wanted, existingCrons[name]); err != nil {
+			return err
+		}
+	}
+	for name, c := range existingCrons {
+		if _, ok := crons[name]; ok {
+			continue
+		}
+		fmt.Fprintf(cli.out, "Removing orphan cron %s\n", c.Name)
+		if err := cli.client.CronDelete(ctx, c.Name); err != nil {
+			return err
+		}
+	}
+
+	existingFuncs, err := cli.composeFuncs(ctx, projectName)
+	if err != nil {
+		return err
+	}
+	names = make([]string, 0, len(funcs))
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		wanted, err := funcFromCompose(projectName, name, funcs[name])
+		if err != nil {
+			return err
+		}
+		current, ok := existingFuncs[name]
+		switch {
+		case !ok:
+			fmt.Fprintf(cli.out, "Creating func %s\n", wanted.Name)
+			_, err = cli.client.FuncCreate(ctx, wanted)
+		case current.Config.Labels[labels.HASH.Str()] != wanted.Config.Labels[labels.HASH.Str()]:
+			fmt.Fprintf(cli.out, "Updating func %s\n", current.Name)
+			_, err = cli.client.FuncUpdate(ctx, current.Name, wanted)
+		default:
+			fmt.Fprintf(cli.out, "Func %s is up-to-date\n", current.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for name, fn := range existingFuncs {
+		if _, ok := funcs[name]; ok {
+			continue
+		}
+		fmt.Fprintf(cli.out, "Removing orphan func %s\n", fn.Name)
+		if err := cli.client.FuncDelete(ctx, fn.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upComposeCron creates the cron wanted, or recreates current if it
+// changed. The old cron is restored if its replacement can't be created.
+func (cli *DockerCli) upComposeCron(ctx context.Context, wanted types.Cron, current types.Cron) error {
+	if current.Name != "" && current.Config.Labels[labels.HASH.Str()] == wanted.Config.Labels[labels.HASH.Str()] {
+		fmt.Fprintf(cli.out, "Cron %s is up-to-date\n", current.Name)
+		return nil
+	}
+	if _, _, err := cli.client.ImageInspectWithRaw(ctx, wanted.Config.Image, false); err != nil && strings.Contains(err.Error(), "No such image") {
+		if err := cli.pullImage(ctx, wanted.Config.Image); err != nil {
+			return err
+		}
+	}
+	if current.Name == "" {
+		fmt.Fprintf(cli.out, "Creating cron %s\n", wanted.Name)
+		_, err := cli.client.CronCreate(ctx, wanted.Name, wanted)
+		return err
+	}
+
+	fmt.Fprintf(cli.out, "Recreating cron %s\n", current.Name)
+	if err := cli.client.CronDelete(ctx, current.Name); err != nil {
+		return err
+	}
+	if _, err := cli.client.CronCreate(ctx, wanted.Name, wanted); err != nil {
+		if _, restoreErr := cli.client.CronCreate(ctx, current.Name, current); restoreErr != nil {
+			return fmt.Errorf("%v, and restoring the previous cron failed: %v", err, restoreErr)
+		}
+		return fmt.Errorf("%v, the previous cron was kept", err)
+	}
+	return nil
+}
+
+// downComposeJobs removes the crons and funcs of a compose project, or
+// only the named ones if services isn't empty.
+func (cli *DockerCli) downComposeJobs(ctx context.Context, projectName string, services []string) error {
+	crons, err := cli.composeCrons(ctx, projectName)
+	if err != nil {
+		return err
+	}
+	names := services
+	if len(names) == 0 {
+		for name := range crons {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		c, ok := crons[name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(cli.out, "Removing cron %s\n", c.Name)
+		if err := cli.client.CronDelete(ctx, c.Name); err != nil {
+			return err
+		}
+	}
+
+	funcs, err := cli.composeFuncs(ctx, projectName)
+	if err != nil {
+		return err
+	}
+	names = services
+	if len(names) == 0 {
+		for name := range funcs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		fn, ok := funcs[name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(cli.out, "Removing func %s\n", fn.Name)
+		if err := cli.client.FuncDelete(ctx, fn.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperhq/hyper-api/types"
+	Cli "github.com/hyperhq/hypercli/cli"
+	"github.com/hyperhq/hypercli/opts"
+	flag "github.com/hyperhq/hypercli/pkg/mflag"
+	"github.com/hyperhq/hypercli/pkg/stdcopy"
+	"github.com/hyperhq/libcompose/labels"
+	"github.com/hyperhq/libcompose/logger"
+	"golang.org/x/net/context"
+)
+
+// composeLogsPollInterval is how often `compose logs -f` looks for new or
+// restarted containers.
+const composeLogsPollInterval = 2 * time.Second
+
+// composeLogs aggregates the logs of the containers of a compose project,
+// one stream per container, each with its own logger.
+type composeLogs struct {
+	cli      *DockerCli
+	project  string
+	services []string
+	options  types.ContainerLogsOptions
+	factory  logger.Factory
+
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	loggers map[string]logger.Logger
+	// streaming holds the containers whose logs are being streamed.
+	streaming map[string]bool
+	// ended holds when the last stream of a container ended, so that a
+	// restarted container is followed from there on.
+	ended map[string]time.Time
+}
+
+// attach starts streaming the logs of the containers not streamed yet. In
+// follow mode, containers are only attached again once running.
+func (l *composeLogs) attach(ctx context.Context) error {
+	containers, err := l.cli.composeContainers(ctx, l.project, l.services)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, c := range containers {
+		if l.streaming[c.ID] {
+			continue
+		}
+		options := l.options
+		if since, ok := l.ended[c.ID]; ok {
+			if c.State != "running" {
+				continue
+			}
+			options.Since = strconv.FormatInt(since.Unix(), 10)
+			options.Tail = "all"
+		}
+		lg, ok := l.loggers[c.ID]
+		if !ok {
+			lg = l.factory.Create(composeContainerLogName(c))
+			l.loggers[c.ID] = lg
+		}
+		l.streaming[c.ID] = true
+		l.wg.Add(1)
+		go func(id string) {
+			defer l.wg.Done()
+			if err := l.stream(ctx, id, options, lg); err != nil {
+				lg.Err([]byte(fmt.Sprintf("error reading logs: %v\n", err)))
+			}
+			l.mu.Lock()
+			delete(l.streaming, id)
+			l.ended[id] = time.Now()
+			l.mu.Unlock()
+		}(c.ID)
+	}
+	return nil
+}
+
+func (l *composeLogs) stream(ctx context.Context, id string, options types.ContainerLogsOptions, lg logger.Logger) error {
+	info, err := l.cli.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return err
+	}
+	body, err := l.cli.client.ContainerLogs(ctx, id, options)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if info.Config.Tty {
+		_, err = io.Copy(&logger.Wrapper{Logger: lg}, body)
+	} else {
+		_, err = stdcopy.StdCopy(&logger.Wrapper{Logger: lg}, &logger.Wrapper{Logger: lg, Err: true}, body)
+	}
+	return err
+}
+
+// composeContainerLogName returns the prefix of the log lines of a
+// container, <service>-<number> like `compose up` does.
+func composeContainerLogName(c types.Container) string {
+	service := c.Labels[labels.SERVICE.Str()]
+	if number := c.Labels[labels.NUMBER.Str()]; service != "" && number != "" {
+		return service + "-" + number
+	}
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return c.ID[:12]
+}
+
+// CmdComposeLogs
+//
+// Usage: hyper compose logs [OPTIONS] [SERVICE...]
+func (cli *DockerCli) CmdComposeLogs(args ...string) error {
+	cmd := Cli.Subcmd("compose logs", []string{"[SERVICE...]"}, "View output from containers.", false)
+	composeFiles := opts.NewListOpts(nil)
+	// -f is --follow here, like for `hyper logs`
+	cmd.Var(&composeFiles, []string{"-file"}, "Specify an alternate compose file (default: docker-compose.yml)")
+	projectName := cmd.String([]string{"p", "-project-name"}, "", "Specify an alternate project name")
+	follow := cmd.Bool([]string{"f", "-follow"}, false, "Follow log output, including new and recreated containers")
+	tail := cmd.String([]string{"-tail"}, "all", "Number of lines to show from the end of the logs\nfor each container")
+	times := cmd.Bool([]string{"t", "-timestamps"}, false, "Show timestamps")
+	noColor := cmd.Bool([]string{"-no-color"}, false, "Produce monochrome output")
+	cmd.Require(flag.Min, 0)
+	err := cmd.ParseFlags(args, true)
+	if err != nil {
+		return err
+	}
+	if err := checkComposeFiles(composeFiles.GetAll()); err != nil {
+		return err
+	}
+	if *projectName == "" {
+		*projectName = getBaseDir()
+	}
+
+	l := &composeLogs{
+		cli:      cli,
+		project:  *projectName,
+		services: cmd.Args(),
+		options: types.ContainerLogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+			Timestamps: *times,
+			Follow:     *follow,
+			Tail:       *tail,
+		},
+		factory:   logger.NewColorLoggerFactory(),
+		loggers:   make(map[string]logger.Logger),
+		streaming: make(map[string]bool),
+		ended:     make(map[string]time.Time),
+	}
+	if *noColor {
+		l.factory = logger.NewPlainLoggerFactory()
+	}
+
+	ctx := context.Background()
+	if err := l.attach(ctx); err != nil {
+		return err
+	}
+	if !*follow {
+		l.wg.Wait()
+		return nil
+	}
+	poll := time.NewTicker(composeLogsPollInterval)
+	defer poll.Stop()
+	for range poll.C {
+		if err := l.attach(ctx); err != nil {
+			fmt.Fprintf(cli.err, "Error listing containers: %v\n", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/hyper-api/types/filters"
+	"github.com/hyperhq/hypercli/api/client/formatter"
+	Cli "github.com/hyperhq/hypercli/cli"
+	flag "github.com/hyperhq/hypercli/pkg/mflag"
+	"github.com/hyperhq/libcompose/labels"
+	"golang.org/x/net/context"
+)
+
+// composeProjectResources holds the resources of the account that may
+// belong to compose projects.
+type composeProjectResources struct {
+	containers []types.Container
+	services   []types.Service
+	volumes    []*types.Volume
+	fips       []map[string]string
+}
+
+// composeProjectList sorts compose projects by name.
+type composeProjectList []formatter.ComposeProject
+
+func (l composeProjectList) Len() int           { return len(l) }
+func (l composeProjectList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l composeProjectList) Less(i, j int) bool { return l[i].Name < l[j].Name }
+
+// composeProjects groups the containers and services of r by the compose
+// project label, along with the volumes labeled with the project or mounted
+// by its containers and the floating IPs attached to them. One-off
+// containers are left out.
+func composeProjects(r composeProjectResources) []formatter.ComposeProject {
+	projects := make(map[string]*formatter.ComposeProject)
+	get := func(name string) *formatter.ComposeProject {
+		p, ok := projects[name]
+		if !ok {
+			p = &formatter.ComposeProject{Name: name, Containers: make(map[string]int)}
+			projects[name] = p
+		}
+		return p
+	}
+	// owners maps the names and IDs of containers and services to their
+	// project
+	owners := make(map[string]string)
+	// mounted maps the names of the volumes mounted by containers to their
+	// project
+	mounted := make(map[string]string)
+	// hashes holds the config hash of each service by project
+	hashes := make(map[string]map[string]string)
+
+	for _, c := range r.containers {
+		name := c.Labels[labels.PROJECT.Str()]
+		if name == "" || c.Labels[labels.ONEOFF.Str()] == "True" {
+			continue
+		}
+		p := get(name)
+		p.Containers[c.State]++
+		if created := time.Unix(c.Created, 0); created.After(p.Updated) {
+			p.Updated = created
+		}
+		owners[c.ID] = name
+		owners[composeContainerName(c)] = name
+		for _, m := range c.Mounts {
+			if m.Name != "" {
+				mounted[m.Name] = name
+			}
+		}
+		if hash := c.Labels[labels.HASH.Str()]; hash != "" {
+			if hashes[name] == nil {
+				hashes[name] = make(map[string]string)
+			}
+			hashes[name][c.Labels[labels.SERVICE.Str()]] = hash
+		}
+	}
+	for _, sv := range r.services {
+		name := sv.Labels[labels.PROJECT.Str()]
+		if name == "" {
+			continue
+		}
+		get(name).Services++
+		owners[sv.Name] = name
+	}
+	for _, v := range r.volumes {
+		// a volume named like the ones of a project may belong to another
+		// project or to none, so the name is not enough
+		name := v.Labels[labels.PROJECT.Str()]
+		if name == "" {
+			name = mounted[v.Name]
+		}
+		if p, ok := projects[name]; ok {
+			p.Volumes++
+		}
+	}
+	for _, fip := range r.fips {
+		for _, owner := range []string{fip["container"], fip["service"]} {
+			if name, ok := owners[owner]; ok && owner != "" {
+				projects[name].Fips++
+				break
+			}
+		}
+	}
+
+	list := make(composeProjectList, 0, len(projects))
+	for name, p := range projects {
+		p.ConfigHash = composeProjectHash(hashes[name])
+		list = append(list, *p)
+	}
+	sort.Sort(list)
+	return list
+}
+
+// composeProjectHash combines the config hashes of the services of a
+// project, as computed by libcompose and stored in the labels of their
+// containers, into a short hash of the project. It is not the hash of the
+// compose file itself: it only changes when the config of a deployed
+// service does, and leaves out the services without containers.
+func composeProjectHash(hashes map[string]string) string {
+	if len(hashes) == 0 {
+		return ""
+	}
+	services := make([]string, 0, len(hashes))
+	for service := range hashes {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	hash := sha1.New()
+	for _, service := range services {
+		io.WriteString(hash, fmt.Sprintf("%s=%s\n", service, hashes[service]))
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// CmdComposeLs lists the compose projects of the account.
+//
+// Usage: hyper compose ls [OPTIONS]
+func (cli *DockerCli) CmdComposeLs(args ...string) error {
+	cmd := Cli.Subcmd("compose ls", []string{}, "List the compose projects of the account.\n\n"+
+		"Projects are found from the labels of their containers and services, wherever\n"+
+		"they were deployed from. The config hash combines the config hashes of the\n"+
+		"services of a project, it changes whenever one of them is recreated with a\n"+
+		"new configuration.", false)
+	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Only display project names")
+	format := cmd.String([]string{"-format"}, "", "Pretty-print projects using a Go template, or 'json'")
+	cmd.Require(flag.Exact, 0)
+	err := cmd.ParseFlags(args, true)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var r composeProjectResources
+	projectFilter := filters.NewArgs()
+	projectFilter.Add("label", labels.PROJECT.Str())
+	if r.containers, err = cli.client.ContainerList(ctx, types.ContainerListOptions{All: true, Filter: projectFilter}); err != nil {
+		return err
+	}
+	if r.services, err = cli.client.ServiceList(ctx, types.ServiceListOptions{}); err != nil {
+		return err
+	}
+	volumes, err := cli.client.VolumeList(ctx, filters.NewArgs())
+	if err != nil {
+		return err
+	}
+	r.volumes = volumes.Volumes
+	if r.fips, err = cli.client.FipList(ctx, types.NetworkListOptions{}); err != nil {
+		return err
+	}
+
+	f := *format
+	if len(f) == 0 {
+		f = "table"
+	}
+	formatter.ComposeProjectContext{
+		Context: formatter.Context{
+			Output: cli.out,
+			Format: f,
+			Quiet:  *quiet,
+		},
+		Projects: composeProjects(r),
+	}.Write()
+	return nil
+}
@@ -0,0 +1,353 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/hyper-api/types/strslice"
+	"github.com/hyperhq/hypercli/pkg/signal"
+	"github.com/hyperhq/libcompose/config"
+	"github.com/hyperhq/libcompose/labels"
+	"github.com/hyperhq/libcompose/project"
+	"github.com/hyperhq/libcompose/yaml"
+	"golang.org/x/net/context"
+)
+
+// Defaults of the x-hyper-service settings, the same as `hyper service create`.
+const (
+	defaultHyperServiceSize                = "s4"
+	defaultHyperServiceHealthCheckInterval = 3
+	defaultHyperServiceHealthCheckFall     = 3
+	defaultHyperServiceHealthCheckRise     = 2
+)
+
+// composeHyperServiceName returns the name of the Hyper service created
+// for a compose service with an x-hyper-service block.
+func composeHyperServiceName(projectName, service string) string {
+	return project.NormalizeName(projectName) + "-" + service
+}
+
+// extractHyperServices removes the services deployed as Hyper services
+// from c and from the requested services, and returns them. Dependencies
+// on them are dropped from the remaining services, which are created as
+// containers by the compose API. Hyper services are created first, so they
+// can't depend on other services.
+func extractHyperServices(c *config.ServiceConfigs, services []string) (map[string]*config.ServiceConfig, []string, error) {
+	hyperServices := make(map[string]*config.ServiceConfig)
+	for name, sc := range c.M {
+		if sc.HyperService != nil {
+			if len(sc.DependsOn) > 0 {
+				return nil, nil, fmt.Errorf("Service %s: depends_on can't be used with x-hyper-service", name)
+			}
+			hyperServices[name] = sc
+		}
+	}
+	if len(hyperServices) == 0 {
+		return hyperServices, services, nil
+	}
+	for name := range hyperServices {
+		delete(c.M, name)
+	}
+	for _, sc := range c.M {
+		var dependsOn yaml.DependsOn
+		for i, dep := range sc.DependsOn.Services() {
+			if _, ok := hyperServices[dep]; !ok {
+				dependsOn = append(dependsOn, sc.DependsOn[i])
+			}
+		}
+		sc.DependsOn = dependsOn
+	}
+
+	if len(services) == 0 {
+		return hyperServices, services, nil
+	}
+	var remaining []string
+	selected := make(map[string]*config.ServiceConfig)
+	for _, name := range services {
+		if sc, ok := hyperServices[name]; ok {
+			selected[name] = sc
+		} else {
+			remaining = append(remaining, name)
+		}
+	}
+	return selected, remaining, nil
+}
+
+// hyperServiceFromCompose converts a compose service into the Hyper service
+// to create. Relative ssl_cert paths are resolved against dir.
+func hyperServiceFromCompose(projectName, name string, sc *config.ServiceConfig, dir string) (types.Service, error) {
+	hs := sc.HyperService
+	sv := types.Service{
+		Name:                composeHyperServiceName(projectName, name),
+		Image:               sc.Image,
+		WorkingDir:          sc.WorkingDir,
+		ContainerSize:       sc.Size,
+		NetMode:             "bridge",
+		StopSignal:          signal.DefaultStopSignal,
+		ServicePort:         hs.ServicePort,
+		ContainerPort:       hs.ContainerPort,
+		Replicas:            hs.Replicas,
+		HealthCheckInterval: hs.HealthCheck.Interval,
+		HealthCheckFall:     hs.HealthCheck.Fall,
+		HealthCheckRise:     hs.HealthCheck.Rise,
+		Algorithm:           hs.Algorithm,
+		Protocol:            hs.Protocol,
+		SessionAffinity:     hs.SessionAffinity,
+		Stdin:               sc.StdinOpen,
+		Tty:                 sc.Tty,
+		Entrypoint:          strslice.StrSlice(sc.Entrypoint),
+		Cmd:                 strslice.StrSlice(sc.Command),
+		Env:                 []string(sc.Environment),
+		Volumes:             make(map[string]struct{}),
+		Labels:              make(map[string]string),
+		SecurityGroups:      make(map[string]struct{}),
+	}
+	if sv.Image == "" {
+		return sv, fmt.Errorf("Service %s: x-hyper-service requires an image", name)
+	}
+	if sv.ContainerSize == "" {
+		sv.ContainerSize = defaultHyperServiceSize
+	}
+	if sv.Replicas == 0 {
+		sv.Replicas = 1
+	}
+	if sv.HealthCheckInterval == 0 {
+		sv.HealthCheckInterval = defaultHyperServiceHealthCheckInterval
+	}
+	if sv.HealthCheckFall == 0 {
+		sv.HealthCheckFall = defaultHyperServiceHealthCheckFall
+	}
+	if sv.HealthCheckRise == 0 {
+		sv.HealthCheckRise = defaultHyperServiceHealthCheckRise
+	}
+	setHyperServiceDefaults(&sv)
+	for _, v := range sc.Volumes {
+		sv.Volumes[v] = struct{}{}
+	}
+	for _, sg := range sc.SecurityGroups {
+		sv.SecurityGroups[sg] = struct{}{}
+	}
+	for k, v := range sc.Labels {
+		sv.Labels[k] = v
+	}
+	sv.Labels[labels.PROJECT.Str()] = project.NormalizeName(projectName)
+	sv.Labels[labels.SERVICE.Str()] = name
+
+	if hs.SSLCert != "" {
+		cert := hs.SSLCert
+		if !filepath.IsAbs(cert) {
+			cert = filepath.Join(dir, cert)
+		}
+		data, err := ioutil.ReadFile(cert)
+		if err != nil {
+			return sv, fmt.Errorf("Service %s: %v", name, err)
+		}
+		sv.SSLCert = string(data)
+	}
+	return sv, nil
+}
+
+// setHyperServiceDefaults fills in the settings of a Hyper service left
+// unset with the values the server defaults them to. Session affinity is
+// off unless set.
+func setHyperServiceDefaults(sv *types.Service) {
+	if sv.ContainerPort == 0 {
+		sv.ContainerPort = sv.ServicePort
+	}
+	if sv.Algorithm == "" {
+		sv.Algorithm = types.LBAlgorithmRoundRobin
+	}
+	if sv.Protocol == "" {
+		sv.Protocol = types.LBProtocolTCP
+	}
+}
+
+// hyperServiceChanges returns the settings of a Hyper service that differ
+// from the wanted ones and can only be changed by recreating it.
+func hyperServiceChanges(existing, wanted types.Service) []string {
+	setHyperServiceDefaults(&existing)
+	setHyperServiceDefaults(&wanted)
+	var changes []string
+	check := func(name string, changed bool) {
+		if changed {
+			changes = append(changes, name)
+		}
+	}
+	check("service_port", existing.ServicePort != wanted.ServicePort)
+	check("container_port", existing.ContainerPort != wanted.ContainerPort)
+	check("protocol", existing.Protocol != wanted.Protocol)
+	check("algorithm", existing.Algorithm != wanted.Algorithm)
+	check("session_affinity", existing.SessionAffinity != wanted.SessionAffinity)
+	check("size", existing.ContainerSize != wanted.ContainerSize)
+	check("environment", !equalStrings(existing.Env, wanted.Env))
+	check("command", !equalStrings(existing.Cmd, wanted.Cmd))
+	check("entrypoint", !equalStrings(existing.Entrypoint, wanted.Entrypoint))
+	check("labels", !containsLabels(existing.Labels, wanted.Labels))
+	check("volumes", !equalSets(existing.Volumes, wanted.Volumes))
+	check("health_check", existing.HealthCheckInterval != wanted.HealthCheckInterval ||
+		existing.HealthCheckFall != wanted.HealthCheckFall ||
+		existing.HealthCheckRise != wanted.HealthCheckRise)
+	check("ssl_cert", existing.SSLCert != wanted.SSLCert)
+	return changes
+}
+
+// equalStrings reports whether a and b hold the same strings, nil being
+// the same as empty.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// containsLabels reports whether labels holds all the wanted labels. The
+// server may add labels of its own.
+func containsLabels(labels, wanted map[string]string) bool {
+	for k, v := range wanted {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+func equalSets(a, b map[string]struct{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// composeHyperServices returns the Hyper services of a compose project,
+// by service name.
+func (cli *DockerCli) composeHyperServices(ctx context.Context, projectName string) (map[string]types.Service, error) {
+	list, err := cli.client.ServiceList(ctx, types.ServiceListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	services := make(map[string]types.Service)
+	for _, sv := range list {
+		if sv.Labels[labels.PROJECT.Str()] == project.NormalizeName(projectName) {
+			services[sv.Labels[labels.SERVICE.Str()]] = sv
+		}
+	}
+	return services, nil
+}
+
+// upHyperServices creates the Hyper services of a compose project, or
+// updates the replicas, image and floating IP of the existing ones. Other
+// changes fail unless forceRecreate is set.
+func (cli *DockerCli) upHyperServices(ctx context.Context, projectName string, services map[string]*config.ServiceConfig, dir string, forceRecreate bool) error {
+	if len(services) == 0 {
+		return nil
+	}
+	existing, err := cli.composeHyperServices(ctx, projectName)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sc := services[name]
+		wanted, err := hyperServiceFromCompose(projectName, name, sc, dir)
+		if err != nil {
+			return err
+		}
+		if _, _, err := cli.client.ImageInspectWithRaw(ctx, wanted.Image, false); err != nil && strings.Contains(err.Error(), "No such image") {
+			if err := cli.pullImage(ctx, wanted.Image); err != nil {
+				return err
+			}
+		}
+
+		current, found := existing[name]
+		if found {
+			if changes := hyperServiceChanges(current, wanted); len(changes) > 0 {
+				if !forceRecreate {
+					return fmt.Errorf("Service %s has changed %s, which requires recreating it: use --force-recreate", current.Name, strings.Join(changes, ", "))
+				} else {
+					fmt.Fprintf(cli.out, "Recreating service %s\n", current.Name)
+					if err := cli.client.ServiceDelete(ctx, current.Name, false); err != nil {
+						return err
+					}
+					found = false
+				}
+			}
+		}
+
+		if !found {
+			fmt.Fprintf(cli.out, "Creating service %s\n", wanted.Name)
+			if current, err = cli.client.ServiceCreate(ctx, wanted); err != nil {
+				return err
+			}
+		} else {
+			update := types.ServiceUpdate{}
+			if current.Replicas != wanted.Replicas {
+				update.Replicas = &wanted.Replicas
+			}
+			if current.Image != wanted.Image {
+				update.Image = &wanted.Image
+			}
+			if update.Replicas != nil || update.Image != nil {
+				fmt.Fprintf(cli.out, "Updating service %s\n", current.Name)
+				if current, err = cli.client.ServiceUpdate(ctx, current.Name, update); err != nil {
+					return err
+				}
+			} else {
+				fmt.Fprintf(cli.out, "Service %s is up-to-date\n", current.Name)
+			}
+		}
+
+		if sc.Fip != "" && sc.Fip != ComposeFipAuto && current.FIP != sc.Fip {
+			fip := sc.Fip
+			if _, err := cli.client.ServiceUpdate(ctx, current.Name, types.ServiceUpdate{FIP: &fip}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// downHyperServices removes the Hyper services of a compose project, or
+// only those of the given compose services.
+func (cli *DockerCli) downHyperServices(ctx context.Context, projectName string, services []string) error {
+	existing, err := cli.composeHyperServices(ctx, projectName)
+	if err != nil {
+		return err
+	}
+	names := services
+	if len(names) == 0 {
+		for name := range existing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		sv, ok := existing[name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(cli.out, "Removing service %s\n", sv.Name)
+		if err := cli.client.ServiceDelete(ctx, sv.Name, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/libcompose/config"
+	"github.com/hyperhq/libcompose/labels"
+)
+
+func TestResolveComposeFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compose-files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("COMPOSE_FILE", os.Getenv("COMPOSE_FILE"))
+	os.Setenv("COMPOSE_FILE", "")
+
+	check := func(flagFiles, expected []string) {
+		if files := resolveComposeFiles(flagFiles); !reflect.DeepEqual(files, expected) {
+			t.Fatalf("Expected %v, got %v", expected, files)
+		}
+	}
+
+	check(nil, []string{"docker-compose.yml"})
+	if err := ioutil.WriteFile(defaultComposeOverrideFile, []byte("version: '2'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	check(nil, []string{"docker-compose.yml", "docker-compose.override.yml"})
+	check([]string{"base.yml", "prod.yml"}, []string{"base.yml", "prod.yml"})
+
+	os.Setenv("COMPOSE_FILE", "base.yml"+string(filepath.ListSeparator)+"staging.yml")
+	check(nil, []string{"base.yml", "staging.yml"})
+	check([]string{"prod.yml"}, []string{"prod.yml"})
+
+	// the files named with -f or COMPOSE_FILE must exist, not the default ones
+	if err := checkComposeFiles([]string{"prod.yml"}); err == nil {
+		t.Fatal("Expected an error for a missing compose file")
+	}
+	if err := checkComposeFiles([]string{defaultComposeOverrideFile, "-"}); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("COMPOSE_FILE", "")
+	if err := checkComposeFiles(nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newHyperServiceConfigs() *config.ServiceConfigs {
+	c := config.NewServiceConfigs()
+	c.Add("web", &config.ServiceConfig{
+		Image:        "nginx",
+		HyperService: &config.HyperService{ServicePort: 80, Protocol: types.LBProtocolHTTP},
+	})
+	c.Add("db", &config.ServiceConfig{Image: "mysql"})
+	c.Add("app", &config.ServiceConfig{Image: "app", DependsOn: []string{"db", "web"}})
+	return c
+}
+
+func TestExtractHyperServices(t *testing.T) {
+	c := newHyperServiceConfigs()
+	hyperServices, services, err := extractHyperServices(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hyperServices) != 1 || hyperServices["web"] == nil {
+		t.Fatalf("Expected the web service only, got %v", hyperServices)
+	}
+	if services != nil {
+		t.Fatalf("Expected all services to be kept, got %v", services)
+	}
+	if c.Has("web") || !c.Has("db") || !c.Has("app") {
+		t.Fatalf("Expected web to be removed from the containers, got %v", c.Keys())
+	}
+	if app, _ := c.Get("app"); !reflect.DeepEqual(app.DependsOn.Services(), []string{"db"}) {
+		t.Fatalf("Expected app to depend on db only, got %v", app.DependsOn)
+	}
+
+	hyperServices, services, _ = extractHyperServices(newHyperServiceConfigs(), []string{"web"})
+	if len(hyperServices) != 1 || len(services) != 0 {
+		t.Fatalf("Expected web to be selected as a Hyper service, got %v and %v", hyperServices, services)
+	}
+	hyperServices, services, _ = extractHyperServices(newHyperServiceConfigs(), []string{"db"})
+	if len(hyperServices) != 0 || !reflect.DeepEqual(services, []string{"db"}) {
+		t.Fatalf("Expected db to be selected as containers, got %v and %v", hyperServices, services)
+	}
+
+	c = newHyperServiceConfigs()
+	web, _ := c.Get("web")
+	web.DependsOn = []string{"db"}
+	if _, _, err := extractHyperServices(c, nil); err == nil {
+		t.Fatal("Expected an error for depends_on on a Hyper service")
+	}
+}
+
+func TestHyperServiceFromCompose(t *testing.T) {
+	c := newHyperServiceConfigs()
+	web, _ := c.Get("web")
+	sv, err := hyperServiceFromCompose("My_App", "web", web, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sv.Name != "myapp-web" || sv.Replicas != 1 || sv.ContainerSize != defaultHyperServiceSize || sv.Algorithm != types.LBAlgorithmRoundRobin || sv.Protocol != types.LBProtocolHTTP || sv.ContainerPort != 80 {
+		t.Fatalf("Unexpected service %+v", sv)
+	}
+	if sv.Labels[labels.PROJECT.Str()] != "myapp" || sv.Labels[labels.SERVICE.Str()] != "web" {
+		t.Fatalf("Unexpected labels %v", sv.Labels)
+	}
+
+	web.HyperService.SSLCert = "missing.pem"
+	if _, err := hyperServiceFromCompose("myapp", "web", web, "."); err == nil {
+		t.Fatal("Expected an error for a missing ssl_cert")
+	}
+}
+
+func TestHyperServiceChanges(t *testing.T) {
+	wanted := types.Service{ServicePort: 80, ContainerPort: 80, Protocol: types.LBProtocolTCP, Algorithm: types.LBAlgorithmRoundRobin}
+	existing := types.Service{ServicePort: 80}
+	if changes := hyperServiceChanges(existing, wanted); len(changes) != 0 {
+		t.Fatalf("Expected the server defaults to match, got changes %v", changes)
+	}
+	existing.ContainerPort = 8080
+	if changes := hyperServiceChanges(existing, wanted); !reflect.DeepEqual(changes, []string{"container_port"}) {
+		t.Fatalf("Expected container_port to change, got %v", changes)
+	}
+
+	existing.ContainerPort = 80
+	existing.Labels = map[string]string{"a": "1", "server": "x"}
+	wanted.Labels = map[string]string{"a": "1"}
+	wanted.Env = []string{"A=1"}
+	wanted.Volumes = map[string]struct{}{"data:/data": {}}
+	wanted.HealthCheckRise = 2
+	expected := []string{"environment", "volumes", "health_check"}
+	if changes := hyperServiceChanges(existing, wanted); !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("Expected %v to change, got %v", expected, changes)
+	}
+}
+
+func TestComposeJobsFromCompose(t *testing.T) {
+	cc := &config.CronConfig{
+		Schedule: "0 3 * * *",
+		Image:    "busybox",
+		Volumes:  []string{"/data", "backup:/backup"},
+	}
+	cron, err := cronFromCompose("My_App", "nightly", cc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cron.Name != "myapp-nightly" || cron.MailPolicy != defaultComposeMailPolicy {
+		t.Fatalf("Unexpected cron %+v", cron)
+	}
+	if len(cron.HostConfig.Binds) != 1 || len(cron.Config.Volumes) != 1 {
+		t.Fatalf("Unexpected volumes %v %v", cron.HostConfig.Binds, cron.Config.Volumes)
+	}
+	l := cron.Config.Labels
+	if l[labels.PROJECT.Str()] != "myapp" || l[labels.SERVICE.Str()] != "nightly" || l["sh_hyper_instancetype"] != defaultComposeJobSize || l[labels.HASH.Str()] == "" {
+		t.Fatalf("Unexpected labels %v", l)
+	}
+
+	cc.Schedule = "0 4 * * *"
+	changed, err := cronFromCompose("My_App", "nightly", cc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Config.Labels[labels.HASH.Str()] == l[labels.HASH.Str()] {
+		t.Fatal("Expected the hash to change with the schedule")
+	}
+	cc.Schedule = ""
+	if _, err := cronFromCompose("My_App", "nightly", cc); err == nil {
+		t.Fatal("Expected an error for a cron without schedule")
+	}
+
+	fn, err := funcFromCompose("My_App", "resize", &config.FuncConfig{Image: "resizer", SecurityGroups: []string{"web"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fn.Name != "myapp-resize" || fn.ContainerSize != defaultComposeJobSize || fn.Timeout != defaultComposeFuncTimeout {
+		t.Fatalf("Unexpected func %+v", fn)
+	}
+	if fn.Config.Labels["sh_hyper_sg_web"] != "yes" || fn.Config.Labels[labels.PROJECT.Str()] != "myapp" {
+		t.Fatalf("Unexpected labels %v", fn.Config.Labels)
+	}
+}
+
+func TestParseComposeFip(t *testing.T) {
+	for fip, expected := range map[string]struct {
+		pool string
+		ok   bool
+	}{
+		"":              {"", false},
+		"1.2.3.4":       {"", false},
+		"auto":          {"", true},
+		"pool:frontend": {"frontend", true},
+	} {
+		pool, ok, err := parseComposeFip(fip)
+		if err != nil || pool != expected.pool || ok != expected.ok {
+			t.Fatalf("%q: expected %v, got %q %v %v", fip, expected, pool, ok, err)
+		}
+	}
+	if _, _, err := parseComposeFip("pool:"); err == nil {
+		t.Fatal("Expected an error for an empty pool name")
+	}
+}
+
+func TestPlanComposeFips(t *testing.T) {
+	requests := map[string]string{"web": "", "api": "", "lb": "frontend", "db": "frontend"}
+	dangling := []map[string]string{
+		{"fip": "10.0.0.3"},
+		{"fip": "10.0.0.1", "name": "frontend"},
+		{"fip": "10.0.0.2"},
+	}
+	kept := map[string]string{"api": "10.0.0.9", "gone": "10.0.0.8"}
+
+	for i := 0; i < 10; i++ {
+		assigned, missing := planComposeFips(requests, kept, dangling)
+		expected := map[string]string{"api": "10.0.0.9", "db": "10.0.0.1", "web": "10.0.0.2"}
+		if !reflect.DeepEqual(assigned, expected) {
+			t.Fatalf("Expected %v, got %v", expected, assigned)
+		}
+		if !reflect.DeepEqual(missing, map[string][]string{"frontend": {"lb"}}) {
+			t.Fatalf("Unexpected missing %v", missing)
+		}
+	}
+}
+
+func TestParseComposeHealthCheck(t *testing.T) {
+	check, err := parseComposeHealthCheck(&config.HealthCheck{Test: []string{"pg_isready -U postgres"}, Interval: "2s", Retries: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(check.cmd, []string{"/bin/sh", "-c", "pg_isready -U postgres"}) || check.interval != 2*time.Second || check.timeout != defaultHealthCheckTimeout || check.retries != 5 {
+		t.Fatalf("Unexpected healthcheck %+v", check)
+	}
+	if check, _ := parseComposeHealthCheck(&config.HealthCheck{Test: []string{"CMD", "redis-cli", "ping"}}); !reflect.DeepEqual(check.cmd, []string{"redis-cli", "ping"}) {
+		t.Fatalf("Unexpected healthcheck %+v", check)
+	}
+	if check, _ := parseComposeHealthCheck(&config.HealthCheck{Port: 5432}); check == nil || check.port != 5432 || check.cmd != nil {
+		t.Fatalf("Unexpected healthcheck %+v", check)
+	}
+	for _, hc := range []*config.HealthCheck{nil, {}, {Test: []string{"NONE"}}, {Port: 80, Disable: true}} {
+		if check, err := parseComposeHealthCheck(hc); check != nil || err != nil {
+			t.Fatalf("Expected no healthcheck for %+v, got %+v %v", hc, check, err)
+		}
+	}
+	if _, err := parseComposeHealthCheck(&config.HealthCheck{Port: 80, Timeout: "soon"}); err == nil {
+		t.Fatal("Expected an error for an invalid timeout")
+	}
+}
+
+func TestComposeStartOrder(t *testing.T) {
+	c := config.NewServiceConfigs()
+	c.Add("db", &config.ServiceConfig{Image: "postgres"})
+	c.Add("migrate", &config.ServiceConfig{Image: "app", DependsOn: []string{"db:service_healthy"}})
+	c.Add("api", &config.ServiceConfig{Image: "app", DependsOn: []string{"db:service_healthy", "migrate:service_completed_successfully"}})
+	c.Add("web", &config.ServiceConfig{Image: "nginx", DependsOn: []string{"api"}})
+	c.Add("cache", &config.ServiceConfig{Image: "redis"})
+
+	if !composeHasConditions(c) {
+		t.Fatal("Expected conditions")
+	}
+	levels, err := composeStartOrder(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{{"cache", "db"}, {"migrate"}, {"api"}, {"web"}}
+	if !reflect.DeepEqual(levels, expected) {
+		t.Fatalf("Expected %v, got %v", expected, levels)
+	}
+	if levels, _ := composeStartOrder(c, []string{"web", "api"}); !reflect.DeepEqual(levels, [][]string{{"api"}, {"web"}}) {
+		t.Fatalf("Unexpected levels %v", levels)
+	}
+
+	db, _ := c.Get("db")
+	db.DependsOn = []string{"web"}
+	if _, err := composeStartOrder(c, nil); err == nil {
+		t.Fatal("Expected a cycle error")
+	}
+}
+
+func TestComposeBuildTag(t *testing.T) {
+	if tag := composeBuildTag("My_App", "web", &config.ServiceConfig{Build: &config.Build{Context: "."}}); tag != "myapp_web" {
+		t.Fatalf("Expected myapp_web, got %s", tag)
+	}
+	if tag := composeBuildTag("myapp", "web", &config.ServiceConfig{Image: "me/web:1.0", Build: &config.Build{Context: "."}}); tag != "me/web:1.0" {
+		t.Fatalf("Expected me/web:1.0, got %s", tag)
+	}
+}
+
+func TestComposeProjects(t *testing.T) {
+	projectLabels := func(project, service, hash string) map[string]string {
+		return map[string]string{
+			labels.PROJECT.Str(): project,
+			labels.SERVICE.Str(): service,
+			labels.ONEOFF.Str():  "False",
+			labels.HASH.Str():    hash,
+		}
+	}
+	oneoff := projectLabels("shop", "web", "h1")
+	oneoff[labels.ONEOFF.Str()] = "True"
+	r := composeProjectResources{
+		containers: []types.Container{
+			{ID: "c1", Names: []string{"/shop_web_1"}, State: "running", Created: 100, Labels: projectLabels("shop", "web", "h1"), Mounts: []types.MountPoint{{Name: "shop_data"}, {Source: "/host"}}},
+			{ID: "c2", Names: []string{"/shop_db_1"}, State: "exited", Created: 200, Labels: projectLabels("shop", "db", "h2")},
+			{ID: "c3", Names: []string{"/shop_web_run_1"}, State: "running", Created: 300, Labels: oneoff},
+			{ID: "c4", Names: []string{"/blog_web_1"}, State: "running", Created: 50, Labels: projectLabels("blog", "web", "h3")},
+		},
+		services: []types.Service{
+			{Name: "shop-api", Labels: map[string]string{labels.PROJECT.Str(): "shop", labels.SERVICE.Str(): "api"}},
+			{Name: "other"},
+		},
+		volumes: []*types.Volume{
+			{Name: "shop_data"},
+			{Name: "shop_cache", Labels: map[string]string{labels.PROJECT.Str(): "shop"}},
+			{Name: "shop_other"},
+			{Name: "blog_data"},
+			{Name: "plain"},
+		},
+		fips: []map[string]string{
+			{"fip": "1.1.1.1", "container": "shop_web_1"},
+			{"fip": "2.2.2.2", "service": "shop-api"},
+			{"fip": "3.3.3.3", "container": "elsewhere"},
+			{"fip": "4.4.4.4"},
+		},
+	}
+
+	projects := composeProjects(r)
+	if len(projects) != 2 || projects[0].Name != "blog" || projects[1].Name != "shop" {
+		t.Fatalf("Expected blog and shop, got %+v", projects)
+	}
+	shop := projects[1]
+	if !reflect.DeepEqual(shop.Containers, map[string]int{"running": 1, "exited": 1}) {
+		t.Fatalf("Unexpected container states %v", shop.Containers)
+	}
+	if shop.Services != 1 || shop.Volumes != 2 || shop.Fips != 2 || shop.Updated.Unix() != 200 {
+		t.Fatalf("Unexpected project %+v", shop)
+	}
+	if shop.ConfigHash != composeProjectHash(map[string]string{"web": "h1", "db": "h2"}) || len(shop.ConfigHash) != 12 {
+		t.Fatalf("Unexpected config hash %s", shop.ConfigHash)
+	}
+	if projects[0].Volumes != 0 {
+		t.Fatalf("Expected blog_data not to belong to blog, got %+v", projects[0])
+	}
+	if projects[0].ConfigHash == shop.ConfigHash {
+		t.Fatal("Expected projects to have different config hashes")
+	}
+}
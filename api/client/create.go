@@ -139,7 +139,7 @@ func (cli *DockerCli) createContainer(ctx context.Context, config *container.Con
 
 	// initialize special volumes
 	if len(initvols) > 0 {
-		err := cli.initVolumes(initvols, false)
+		err := cli.initVolumes(initvols, false, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -4,16 +4,94 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/hyperhq/hyper-api/types/events"
+	Cli "github.com/hyperhq/hypercli/cli"
+	flag "github.com/hyperhq/hypercli/pkg/mflag"
 	signutil "github.com/hyperhq/websocket-client/go/util"
 	"golang.org/x/net/context"
 )
 
+// eventsReconnectDelay is how long `hyper events` waits before
+// resubscribing after the event stream is interrupted.
+const eventsReconnectDelay = 5 * time.Second
+
+// CmdEvents prints events from the server or, with --exec, runs the
+// actions of a rules file for every matching event.
+//
+// Usage: hyper events [OPTIONS]
+func (cli *DockerCli) CmdEvents(args ...string) error {
+	cmd := Cli.Subcmd("events", nil, Cli.DockerCommands["events"].Description, true)
+	flExec := cmd.String([]string{"-exec"}, "", "Run the commands and webhooks of a YAML rules file for matching events")
+	cmd.Require(flag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	handle := func(e events.Message) {
+		fmt.Fprintln(cli.out, formatEvent(e))
+	}
+	ctx := context.Background()
+	if *flExec != "" {
+		rules, err := loadEventHookRules(*flExec)
+		if err != nil {
+			return fmt.Errorf("Error loading rules file %s: %v", *flExec, err)
+		}
+		runner := &eventHookRunner{rules: rules, out: cli.out, err: cli.err}
+		runner.start(ctx)
+		handle = runner.dispatch
+	}
+
+	for {
+		err := cli.handleEvents(ctx, handle)
+		if *flExec == "" {
+			return err
+		}
+		// The hook runner is meant to run unattended, keep going.
+		fmt.Fprintf(cli.err, "Event stream interrupted: %v, reconnecting in %s\n", err, eventsReconnectDelay)
+		time.Sleep(eventsReconnectDelay)
+	}
+}
+
+// handleEvents calls handle for every event until the stream fails. The
+// stream is closed before returning.
+func (cli *DockerCli) handleEvents(ctx context.Context, handle func(events.Message)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	eventq, errq := cli.Events(ctx)
+	for {
+		select {
+		case e := <-eventq:
+			handle(e)
+		case err := <-errq:
+			return err
+		}
+	}
+}
+
+func formatEvent(e events.Message) string {
+	var attrs []string
+	for k, v := range e.Actor.Attributes {
+		attrs = append(attrs, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(attrs)
+	t := time.Unix(e.Time, 0)
+	if e.TimeNano != 0 {
+		t = time.Unix(0, e.TimeNano)
+	}
+	s := fmt.Sprintf("%s %s %s %s", t.Format(time.RFC3339Nano), e.Type, e.Action, e.Actor.ID)
+	if len(attrs) > 0 {
+		s += " (" + strings.Join(attrs, ", ") + ")"
+	}
+	return s
+}
+
 // Events returns a stream of events in the daemon. It's up to the caller to close the stream
 // by cancelling the context. Once the stream has been completely read an io.EOF error will
 // be sent over the error channel. If an error is sent all processing will be stopped. It's up
@@ -63,6 +141,17 @@ func (cli *DockerCli) Events(ctx context.Context) (<-chan events.Message, <-chan
 		}
 		defer ws.Close()
 
+		// unblock the read below once the stream is cancelled
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				ws.Close()
+			case <-done:
+			}
+		}()
+
 		if resp.ContentLength > 0 {
 			defer resp.Body.Close()
 			ioutil.ReadAll(resp.Body)
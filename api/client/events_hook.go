@@ -0,0 +1,323 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperhq/hyper-api/types/events"
+	"golang.org/x/net/context"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	hookInputEnv  = "env"
+	hookInputJSON = "json"
+
+	defaultHookTimeout = 5 * time.Minute
+	// hookQueueSize is the number of events a rule holds while its actions
+	// are busy, the following events are dropped.
+	hookQueueSize = 100
+)
+
+// hookStringList is a YAML value that can be written either as a single
+// string or as a list of strings.
+type hookStringList []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (l *hookStringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		*l = hookStringList{s}
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return fmt.Errorf("must be a string or a list of strings")
+	}
+	*l = hookStringList(list)
+	return nil
+}
+
+// matches reports whether value matches any of the patterns in the list.
+// An empty list matches everything; patterns use path.Match syntax.
+func (l hookStringList) matches(value string) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, pattern := range l {
+		if ok, err := path.Match(pattern, value); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// eventHookMatch selects the events a rule reacts to. All the non empty
+// fields must match.
+type eventHookMatch struct {
+	Type       hookStringList    `yaml:"type"`
+	Action     hookStringList    `yaml:"action"`
+	Name       hookStringList    `yaml:"name"`
+	Attributes map[string]string `yaml:"attributes"`
+}
+
+// eventHookRule is one entry of the rules file: a match filter plus the
+// command and/or webhook to run for each matching event.
+type eventHookRule struct {
+	Name        string         `yaml:"name"`
+	Match       eventHookMatch `yaml:"match"`
+	Command     hookStringList `yaml:"command"`
+	Webhook     string         `yaml:"webhook"`
+	Input       string         `yaml:"input"`
+	Concurrency int            `yaml:"concurrency"`
+	Timeout     string         `yaml:"timeout"`
+
+	timeout time.Duration
+	queue   chan events.Message
+}
+
+type eventHookRules struct {
+	Rules []*eventHookRule `yaml:"rules"`
+}
+
+// loadEventHookRules reads and validates the rules file used by `hyper events --exec`.
+func loadEventHookRules(file string) ([]*eventHookRule, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseEventHookRules(data)
+}
+
+func parseEventHookRules(data []byte) ([]*eventHookRule, error) {
+	var rules eventHookRules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	if len(rules.Rules) == 0 {
+		return nil, fmt.Errorf("no rules defined")
+	}
+	for i, r := range rules.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if len(r.Command) == 0 && r.Webhook == "" {
+			return nil, fmt.Errorf("rule %s: either command or webhook must be specified", r.Name)
+		}
+		switch r.Input {
+		case "":
+			r.Input = hookInputEnv
+		case hookInputEnv, hookInputJSON:
+		default:
+			return nil, fmt.Errorf("rule %s: invalid input %q, must be %q or %q", r.Name, r.Input, hookInputEnv, hookInputJSON)
+		}
+		if r.Concurrency < 0 {
+			return nil, fmt.Errorf("rule %s: concurrency must be a positive number", r.Name)
+		} else if r.Concurrency == 0 {
+			r.Concurrency = 1
+		}
+		r.timeout = defaultHookTimeout
+		if r.Timeout != "" {
+			var err error
+			if r.timeout, err = time.ParseDuration(r.Timeout); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid timeout: %v", r.Name, err)
+			}
+		}
+		r.queue = make(chan events.Message, hookQueueSize)
+	}
+	return rules.Rules, nil
+}
+
+func (r *eventHookRule) matches(e events.Message) bool {
+	m := r.Match
+	if !m.Type.matches(e.Type) || !m.Action.matches(e.Action) {
+		return false
+	}
+	if !m.Name.matches(e.Actor.Attributes["name"]) {
+		return false
+	}
+	for k, v := range m.Attributes {
+		if ok, err := path.Match(v, e.Actor.Attributes[k]); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// eventEnv converts the event into HYPER_EVENT_* environment variables.
+// Actor attributes are exported as HYPER_EVENT_ATTR_<KEY>.
+func eventEnv(e events.Message) []string {
+	env := []string{
+		"HYPER_EVENT_TYPE=" + e.Type,
+		"HYPER_EVENT_ACTION=" + e.Action,
+		"HYPER_EVENT_ID=" + e.Actor.ID,
+		"HYPER_EVENT_NAME=" + e.Actor.Attributes["name"],
+		"HYPER_EVENT_TIME=" + strconv.FormatInt(e.Time, 10),
+	}
+	keys := make([]string, 0, len(e.Actor.Attributes))
+	for k := range e.Actor.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := strings.ToUpper(strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return '_'
+		}, k))
+		env = append(env, "HYPER_EVENT_ATTR_"+name+"="+e.Actor.Attributes[k])
+	}
+	return env
+}
+
+// eventHookRunner dispatches events to the matching rules and reports
+// the result of every action.
+type eventHookRunner struct {
+	rules []*eventHookRule
+	out   io.Writer
+	err   io.Writer
+}
+
+// start runs the actions of the queued events of every rule, at most
+// Concurrency at a time, until ctx is done.
+func (h *eventHookRunner) start(ctx context.Context) {
+	for _, r := range h.rules {
+		for i := 0; i < r.Concurrency; i++ {
+			go func(r *eventHookRule) {
+				for {
+					select {
+					case e := <-r.queue:
+						h.run(ctx, r, e)
+					case <-ctx.Done():
+						return
+					}
+				}
+			}(r)
+		}
+	}
+}
+
+// dispatch queues e for every rule it matches. It never blocks the event
+// stream: the event is dropped for a rule whose queue is full.
+func (h *eventHookRunner) dispatch(e events.Message) {
+	for _, r := range h.rules {
+		if !r.matches(e) {
+			continue
+		}
+		select {
+		case r.queue <- e:
+		default:
+			h.log(h.err, r, e, "%d events already queued, dropping the event", hookQueueSize)
+		}
+	}
+}
+
+func (h *eventHookRunner) run(ctx context.Context, r *eventHookRule, e events.Message) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		h.log(h.err, r, e, "encoding the event failed: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if len(r.Command) > 0 {
+		start := time.Now()
+		if err := runHookCommand(ctx, r, e, payload); err != nil {
+			h.log(h.err, r, e, "command failed after %s: %v", time.Since(start), err)
+		} else {
+			h.log(h.out, r, e, "command finished in %s", time.Since(start))
+		}
+	}
+	if r.Webhook != "" {
+		start := time.Now()
+		if status, err := postHookWebhook(ctx, r, payload); err != nil {
+			h.log(h.err, r, e, "webhook failed after %s: %v", time.Since(start), err)
+		} else {
+			h.log(h.out, r, e, "webhook returned %s in %s", status, time.Since(start))
+		}
+	}
+}
+
+func (h *eventHookRunner) log(w io.Writer, r *eventHookRule, e events.Message, format string, args ...interface{}) {
+	prefix := fmt.Sprintf("%s [%s] %s %s %s", time.Now().Format(time.RFC3339), r.Name, e.Type, e.Action, e.Actor.ID)
+	fmt.Fprintf(w, "%s: %s\n", prefix, fmt.Sprintf(format, args...))
+}
+
+func runHookCommand(ctx context.Context, r *eventHookRule, e events.Message, payload []byte) error {
+	var argv []string
+	if len(r.Command) == 1 {
+		// A single string is handed to the shell, so pipes and redirections work.
+		if runtime.GOOS == "windows" {
+			argv = []string{"cmd", "/S", "/C", r.Command[0]}
+		} else {
+			argv = []string{"/bin/sh", "-c", r.Command[0]}
+		}
+	} else {
+		argv = r.Command
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	// the children of the command are killed along with it on timeout, or
+	// they would keep its output open
+	setHookProcessGroup(cmd)
+	cmd.Env = append(os.Environ(), eventEnv(e)...)
+	if r.Input == hookInputJSON {
+		cmd.Stdin = bytes.NewReader(payload)
+	}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		if err != nil && output.Len() > 0 {
+			return fmt.Errorf("%v: %s", err, strings.TrimSpace(output.String()))
+		}
+		return err
+	case <-ctx.Done():
+		killHookProcess(cmd)
+		<-done
+		return ctx.Err()
+	}
+}
+
+func postHookWebhook(ctx context.Context, r *eventHookRule, payload []byte) (string, error) {
+	req, err := http.NewRequest("POST", r.Webhook, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Cancel = ctx.Done()
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.Status, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.Status, nil
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/hyper-api/types/events"
+	"golang.org/x/net/context"
+)
+
+func TestParseEventHookRules(t *testing.T) {
+	rules, err := parseEventHookRules([]byte(`
+rules:
+  - name: on-die
+    match:
+      type: container
+      action: [die, oom]
+      attributes:
+        com.example.env: prod*
+    command: echo $HYPER_EVENT_ID
+    concurrency: 2
+  - match:
+      type: cron
+    webhook: https://example.com/hook
+    input: json
+    timeout: 10s
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(rules))
+	}
+	if rules[0].Concurrency != 2 || rules[0].Input != hookInputEnv {
+		t.Fatalf("Unexpected defaults for first rule: %+v", rules[0])
+	}
+	if rules[1].Name != "rule-2" || rules[1].Concurrency != 1 || rules[1].timeout.Seconds() != 10 {
+		t.Fatalf("Unexpected defaults for second rule: %+v", rules[1])
+	}
+
+	invalids := []string{
+		``,
+		"rules:\n  - match: {type: container}\n",
+		"rules:\n  - command: true\n    input: xml\n",
+		"rules:\n  - command: true\n    timeout: soon\n",
+	}
+	for _, data := range invalids {
+		if _, err := parseEventHookRules([]byte(data)); err == nil {
+			t.Fatalf("Expected an error for %q", data)
+		}
+	}
+}
+
+func TestEventHookRuleMatches(t *testing.T) {
+	rules, err := parseEventHookRules([]byte(`
+rules:
+  - match:
+      type: container
+      action: [die, oom]
+      name: web-*
+      attributes:
+        env: prod*
+    command: "true"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rules[0]
+	e := events.Message{
+		Type:   "container",
+		Action: "die",
+		Actor:  events.Actor{ID: "abc", Attributes: map[string]string{"name": "web-1", "env": "production"}},
+	}
+	if !r.matches(e) {
+		t.Fatalf("Expected %+v to match", e)
+	}
+	e.Action = "start"
+	if r.matches(e) {
+		t.Fatalf("Expected %+v not to match on action", e)
+	}
+	e.Action = "oom"
+	e.Actor.Attributes["env"] = "staging"
+	if r.matches(e) {
+		t.Fatalf("Expected %+v not to match on attributes", e)
+	}
+}
+
+func TestEventEnv(t *testing.T) {
+	env := eventEnv(events.Message{
+		Type:   "container",
+		Action: "die",
+		Time:   42,
+		Actor:  events.Actor{ID: "abc", Attributes: map[string]string{"name": "web", "exitCode": "1", "com.example.env": "prod"}},
+	})
+	expected := []string{
+		"HYPER_EVENT_TYPE=container",
+		"HYPER_EVENT_ACTION=die",
+		"HYPER_EVENT_ID=abc",
+		"HYPER_EVENT_NAME=web",
+		"HYPER_EVENT_TIME=42",
+		"HYPER_EVENT_ATTR_COM_EXAMPLE_ENV=prod",
+		"HYPER_EVENT_ATTR_EXITCODE=1",
+		"HYPER_EVENT_ATTR_NAME=web",
+	}
+	if len(env) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, env)
+	}
+	for i := range expected {
+		if env[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, env)
+		}
+	}
+}
+
+func TestRunHookCommandTimeoutKillsChildren(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are not supported on windows")
+	}
+	r := &eventHookRule{Command: hookStringList{"sleep 10 & sleep 10"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := runHookCommand(ctx, r, events.Message{}, nil); err != context.DeadlineExceeded {
+		t.Fatalf("Expected the command to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Expected the children of the command to be killed, waited %s", elapsed)
+	}
+}
+
+func TestEventHookRunnerDropsEventsOfBusyRules(t *testing.T) {
+	rules, err := parseEventHookRules([]byte(`
+rules:
+  - name: slow
+    match:
+      action: die
+    command: sleep 10
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errBuf := new(bytes.Buffer)
+	h := &eventHookRunner{rules: rules, out: ioutil.Discard, err: errBuf}
+
+	// without workers the queue of the rule fills up, dispatch must not block
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < hookQueueSize+2; i++ {
+			h.dispatch(events.Message{Type: "container", Action: "die"})
+		}
+		h.dispatch(events.Message{Type: "container", Action: "start"})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected dispatch not to block on a busy rule")
+	}
+	if n := len(rules[0].queue); n != hookQueueSize {
+		t.Fatalf("Expected %d queued events, got %d", hookQueueSize, n)
+	}
+	if n := strings.Count(errBuf.String(), "dropping the event"); n != 2 {
+		t.Fatalf("Expected 2 dropped events, got %d: %s", n, errBuf.String())
+	}
+}
@@ -0,0 +1,18 @@
+// +build !windows
+
+package client
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setHookProcessGroup runs the command of a hook in its own process group.
+func setHookProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killHookProcess kills the command of a hook and all its children.
+func killHookProcess(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
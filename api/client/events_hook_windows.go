@@ -0,0 +1,14 @@
+// +build windows
+
+package client
+
+import "os/exec"
+
+func setHookProcessGroup(cmd *exec.Cmd) {
+}
+
+// killHookProcess kills the command of a hook. Its children are left
+// running, Windows has no process groups to kill them with.
+func killHookProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -10,6 +10,7 @@ import (
 
 	"golang.org/x/net/context"
 
+	"github.com/hyperhq/hyper-api/client"
 	"github.com/hyperhq/hyper-api/types"
 	"github.com/hyperhq/hyper-api/types/filters"
 	Cli "github.com/hyperhq/hypercli/cli"
@@ -163,6 +164,7 @@ func (cli *DockerCli) CmdFipLs(args ...string) error {
 
 	flFilter := opts.NewListOpts(nil)
 	cmd.Var(&flFilter, []string{"f", "-filter"}, "Filter output based on conditions provided")
+	flRegion := addRegionFlags(cmd)
 
 	cmd.Require(flag.Exact, 0)
 	err := cmd.ParseFlags(args, true)
@@ -183,14 +185,25 @@ func (cli *DockerCli) CmdFipLs(args ...string) error {
 		Filters: fipFilterArgs,
 	}
 
-	fips, err := cli.client.FipList(context.Background(), options)
+	regions, err := flRegion.list()
+	if err != nil {
+		return err
+	}
+	lists := make([][]map[string]string, regionCount(regions))
+	err = cli.forEachRegion(regions, func(i int, region string, c client.APIClient) error {
+		fips, err := c.FipList(context.Background(), options)
+		lists[i] = fips
+		return err
+	})
 	if err != nil {
 		return err
 	}
 	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
-	fmt.Fprintf(w, "Floating IP\tName\tContainer\tService\n")
-	for _, fip := range fips {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", fip["fip"], fip["name"], fip["container"], fip["service"])
+	fmt.Fprintf(w, "%sFloating IP\tName\tContainer\tService\n", regionColumn(regions, -1))
+	for i, fips := range lists {
+		for _, fip := range fips {
+			fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\n", regionColumn(regions, i), fip["fip"], fip["name"], fip["container"], fip["service"])
+		}
 	}
 
 	w.Flush()
@@ -2,6 +2,7 @@ package formatter
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -13,9 +14,11 @@ import (
 	"github.com/hyperhq/hypercli/pkg/stringutils"
 )
 
+// FipLabel is the label holding the floating IP of a container.
+const FipLabel = "sh.hyper.fip"
+
 const (
 	tableKey = "table"
-	fipLabel = "sh.hyper.fip"
 
 	containerIDHeader     = "CONTAINER ID"
 	imageHeader           = "IMAGE"
@@ -37,12 +40,29 @@ const (
 	volumeSizeHeader      = "SIZE"
 	volumeDriverHeader    = "DRIVER"
 	volumeContainerHeader = "CONTAINER"
+	snapshotIDHeader      = "SNAPSHOT ID"
+	snapshotNameHeader    = "NAME"
+	snapshotVolumeHeader  = "VOLUME"
+	projectNameHeader     = "NAME"
+	projectServicesHeader = "SERVICES"
+	projectVolumesHeader  = "VOLUMES"
+	projectFipsHeader     = "FIPS"
+	projectHashHeader     = "CONFIG HASH"
+	updatedSinceHeader    = "UPDATED"
+	updatedAtHeader       = "UPDATED AT"
+	regionHeader          = "REGION"
 )
 
 type containerContext struct {
 	baseSubContext
-	trunc bool
-	c     types.Container
+	trunc  bool
+	c      types.Container
+	region string
+}
+
+func (c *containerContext) Region() string {
+	c.addHeader(regionHeader)
+	return c.region
 }
 
 func (c *containerContext) ID() string {
@@ -154,7 +174,7 @@ func (c *containerContext) PublicIP() string {
 	if c.c.Labels == nil {
 		return ""
 	}
-	return c.c.Labels[fipLabel]
+	return c.c.Labels[FipLabel]
 }
 
 type imageContext struct {
@@ -164,6 +184,12 @@ type imageContext struct {
 	repo   string
 	tag    string
 	digest string
+	region string
+}
+
+func (c *imageContext) Region() string {
+	c.addHeader(regionHeader)
+	return c.region
 }
 
 func (c *imageContext) ID() string {
@@ -207,7 +233,13 @@ func (c *imageContext) Size() string {
 
 type volumeContext struct {
 	baseSubContext
-	i types.Volume
+	i      types.Volume
+	region string
+}
+
+func (c *volumeContext) Region() string {
+	c.addHeader(regionHeader)
+	return c.region
 }
 
 func (c *volumeContext) Name() string {
@@ -232,11 +264,116 @@ func (c *volumeContext) Container() string {
 	return container
 }
 
+type snapshotContext struct {
+	baseSubContext
+	s types.Snapshot
+}
+
+func (c *snapshotContext) ID() string {
+	c.addHeader(snapshotIDHeader)
+	return c.s.ID
+}
+
+func (c *snapshotContext) Name() string {
+	c.addHeader(snapshotNameHeader)
+	return c.s.Name
+}
+
+func (c *snapshotContext) Volume() string {
+	c.addHeader(snapshotVolumeHeader)
+	return c.s.Volume
+}
+
+func (c *snapshotContext) Size() string {
+	c.addHeader(sizeHeader)
+	return fmt.Sprintf("%d GB", c.s.Size)
+}
+
+// CreatedSince is empty for snapshots of servers not reporting their
+// creation time.
+func (c *snapshotContext) CreatedSince() string {
+	c.addHeader(createdSinceHeader)
+	if c.s.Created == 0 {
+		return ""
+	}
+	createdAt := time.Unix(c.s.Created, 0)
+	return units.HumanDuration(time.Now().UTC().Sub(createdAt)) + " ago"
+}
+
+func (c *snapshotContext) CreatedAt() string {
+	c.addHeader(createdAtHeader)
+	if c.s.Created == 0 {
+		return ""
+	}
+	return time.Unix(c.s.Created, 0).String()
+}
+
 type subContext interface {
 	fullHeader() string
 	addHeader(header string)
 }
 
+type composeProjectContext struct {
+	baseSubContext
+	p ComposeProject
+}
+
+func (c *composeProjectContext) Name() string {
+	c.addHeader(projectNameHeader)
+	return c.p.Name
+}
+
+// Status counts the containers of the project by state, as in
+// "running(2), exited(1)".
+func (c *composeProjectContext) Status() string {
+	c.addHeader(statusHeader)
+	var states []string
+	for state := range c.p.Containers {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+	for i, state := range states {
+		states[i] = fmt.Sprintf("%s(%d)", state, c.p.Containers[state])
+	}
+	return strings.Join(states, ", ")
+}
+
+func (c *composeProjectContext) Services() int {
+	c.addHeader(projectServicesHeader)
+	return c.p.Services
+}
+
+func (c *composeProjectContext) Volumes() int {
+	c.addHeader(projectVolumesHeader)
+	return c.p.Volumes
+}
+
+func (c *composeProjectContext) Fips() int {
+	c.addHeader(projectFipsHeader)
+	return c.p.Fips
+}
+
+func (c *composeProjectContext) ConfigHash() string {
+	c.addHeader(projectHashHeader)
+	return c.p.ConfigHash
+}
+
+func (c *composeProjectContext) UpdatedSince() string {
+	c.addHeader(updatedSinceHeader)
+	if c.p.Updated.IsZero() {
+		return ""
+	}
+	return units.HumanDuration(time.Now().UTC().Sub(c.p.Updated)) + " ago"
+}
+
+func (c *composeProjectContext) UpdatedAt() string {
+	c.addHeader(updatedAtHeader)
+	if c.p.Updated.IsZero() {
+		return ""
+	}
+	return c.p.Updated.String()
+}
+
 type baseSubContext struct {
 	header []string
 }
@@ -2,11 +2,13 @@ package formatter
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
 	"text/tabwriter"
 	"text/template"
+	"time"
 
 	"github.com/hyperhq/hyper-api/types"
 	"github.com/hyperhq/hypercli/reference"
@@ -15,11 +17,14 @@ import (
 const (
 	tableFormatKey = "table"
 	rawFormatKey   = "raw"
+	jsonFormatKey  = "json"
 
 	defaultContainerTableFormat       = "table {{.ID}}\t{{.Image}}\t{{.Command}}\t{{.RunningFor}} ago\t{{.Status}}\t{{.Ports}}\t{{.Names}}\t{{.PublicIP}}"
 	defaultImageTableFormat           = "table {{.Repository}}\t{{.Tag}}\t{{.ID}}\t{{.CreatedSince}} ago\t{{.Size}}"
 	defaultImageTableFormatWithDigest = "table {{.Repository}}\t{{.Tag}}\t{{.Digest}}\t{{.ID}}\t{{.CreatedSince}} ago\t{{.Size}}"
 	defaultVolumeTableFormat          = "table {{.Driver}}\t{{.Name}}\t{{.Size}}\t{{.Container}}"
+	defaultSnapshotTableFormat        = "table {{.Name}}\t{{.Volume}}\t{{.Size}}\t{{.CreatedSince}}"
+	defaultComposeProjectTableFormat  = "table {{.Name}}\t{{.Status}}\t{{.Services}}\t{{.Volumes}}\t{{.Fips}}\t{{.ConfigHash}}\t{{.UpdatedSince}}"
 	defaultQuietFormat                = "{{.ID}}"
 )
 
@@ -81,6 +86,23 @@ func (c *Context) postformat(tmpl *template.Template, subContext subContext) {
 	}
 }
 
+// addRegionColumn prepends the region to the rows of a table listing
+// several regions, unless the format already shows it.
+func (c *Context) addRegionColumn(regions []string) {
+	if c.table && regions != nil && !strings.Contains(c.Format, "{{.Region}}") {
+		c.finalFormat = "{{.Region}}\t" + c.finalFormat
+	}
+}
+
+// regionAt returns the region of the i-th element of a listing, if it
+// covers several regions.
+func regionAt(regions []string, i int) string {
+	if i < len(regions) {
+		return regions[i]
+	}
+	return ""
+}
+
 func (c *Context) contextFormat(tmpl *template.Template, subContext subContext) error {
 	if err := tmpl.Execute(c.buffer, subContext); err != nil {
 		c.buffer = bytes.NewBufferString(fmt.Sprintf("Template parsing error: %v\n", err))
@@ -101,6 +123,9 @@ type ContainerContext struct {
 	Size bool
 	// Containers
 	Containers []types.Container
+	// Regions holds the region of each container, when listing several
+	// regions.
+	Regions []string
 }
 
 // ImageContext contains image specific information required by the formater, encapsulate a Context struct.
@@ -109,12 +134,48 @@ type ImageContext struct {
 	Digest bool
 	// Images
 	Images []types.Image
+	// Regions holds the region of each image, when listing several
+	// regions.
+	Regions []string
 }
 
 type VolumeContext struct {
 	Context
 	// Volumes
 	Volumes []*types.Volume
+	// Regions holds the region of each volume, when listing several
+	// regions.
+	Regions []string
+}
+
+// SnapshotContext contains snapshot specific information required by the formater, encapsulate a Context struct.
+type SnapshotContext struct {
+	Context
+	// Snapshots
+	Snapshots []*types.Snapshot
+}
+
+// ComposeProject is a compose project found on the account, made of the
+// containers, services, volumes and floating IPs labeled with its name.
+type ComposeProject struct {
+	Name string
+	// Containers counts the containers of the project by state.
+	Containers map[string]int
+	Services   int
+	Volumes    int
+	Fips       int
+	// ConfigHash combines the config hashes of the services of the
+	// project, it changes whenever the configuration of one of its
+	// deployed services does.
+	ConfigHash string
+	Updated    time.Time
+}
+
+// ComposeProjectContext contains compose project specific information required by the formater, encapsulate a Context struct.
+type ComposeProjectContext struct {
+	Context
+	// Projects
+	Projects []ComposeProject
 }
 
 func (ctx ContainerContext) Write() {
@@ -150,16 +211,18 @@ ports: {{.Ports}}
 	if ctx.table && ctx.Size {
 		ctx.finalFormat += "\t{{.Size}}"
 	}
+	ctx.addRegionColumn(ctx.Regions)
 
 	tmpl, err := ctx.parseFormat()
 	if err != nil {
 		return
 	}
 
-	for _, container := range ctx.Containers {
+	for i, container := range ctx.Containers {
 		containerCtx := &containerContext{
-			trunc: ctx.Trunc,
-			c:     container,
+			trunc:  ctx.Trunc,
+			c:      container,
+			region: regionAt(ctx.Regions, i),
 		}
 		err = ctx.contextFormat(tmpl, containerCtx)
 		if err != nil {
@@ -208,13 +271,14 @@ virtual_size: {{.Size}}
 	if ctx.table && ctx.Digest && !strings.Contains(ctx.Format, "{{.Digest}}") {
 		ctx.finalFormat += "\t{{.Digest}}"
 	}
+	ctx.addRegionColumn(ctx.Regions)
 
 	tmpl, err := ctx.parseFormat()
 	if err != nil {
 		return
 	}
 
-	for _, image := range ctx.Images {
+	for i, image := range ctx.Images {
 
 		repoTags := image.RepoTags
 		repoDigests := image.RepoDigests
@@ -250,6 +314,7 @@ virtual_size: {{.Size}}
 				repo:   repo,
 				tag:    tag,
 				digest: digest,
+				region: regionAt(ctx.Regions, i),
 			}
 			err = ctx.contextFormat(tmpl, imageCtx)
 			if err != nil {
@@ -282,15 +347,17 @@ container: {{.Container}}
 
 	ctx.buffer = bytes.NewBufferString("")
 	ctx.preformat()
+	ctx.addRegionColumn(ctx.Regions)
 
 	tmpl, err := ctx.parseFormat()
 	if err != nil {
 		return
 	}
 
-	for _, vol := range ctx.Volumes {
+	for i, vol := range ctx.Volumes {
 		volCtx := &volumeContext{
-			i: *vol,
+			i:      *vol,
+			region: regionAt(ctx.Regions, i),
 		}
 		err = ctx.contextFormat(tmpl, volCtx)
 		if err != nil {
@@ -300,3 +367,98 @@ container: {{.Container}}
 
 	ctx.postformat(tmpl, &volumeContext{})
 }
+
+func (ctx SnapshotContext) Write() {
+	switch ctx.Format {
+	case tableFormatKey:
+		ctx.Format = defaultSnapshotTableFormat
+		if ctx.Quiet {
+			ctx.Format = "{{.Name}}"
+		}
+	case rawFormatKey:
+		if ctx.Quiet {
+			ctx.Format = `name: {{.Name}}`
+		} else {
+			ctx.Format = `name: {{.Name}}
+volume: {{.Volume}}
+size: {{.Size}}
+created_at: {{.CreatedAt}}
+`
+		}
+	}
+
+	ctx.buffer = bytes.NewBufferString("")
+	ctx.preformat()
+
+	tmpl, err := ctx.parseFormat()
+	if err != nil {
+		return
+	}
+
+	for _, snap := range ctx.Snapshots {
+		snapCtx := &snapshotContext{
+			s: *snap,
+		}
+		err = ctx.contextFormat(tmpl, snapCtx)
+		if err != nil {
+			return
+		}
+	}
+
+	ctx.postformat(tmpl, &snapshotContext{})
+}
+
+func (ctx ComposeProjectContext) Write() {
+	switch ctx.Format {
+	case tableFormatKey:
+		ctx.Format = defaultComposeProjectTableFormat
+		if ctx.Quiet {
+			ctx.Format = "{{.Name}}"
+		}
+	case rawFormatKey:
+		if ctx.Quiet {
+			ctx.Format = `name: {{.Name}}`
+		} else {
+			ctx.Format = `name: {{.Name}}
+status: {{.Status}}
+services: {{.Services}}
+volumes: {{.Volumes}}
+fips: {{.Fips}}
+config_hash: {{.ConfigHash}}
+updated_at: {{.UpdatedAt}}
+`
+		}
+	case jsonFormatKey:
+		projects := ctx.Projects
+		if projects == nil {
+			projects = []ComposeProject{}
+		}
+		data, err := json.MarshalIndent(projects, "", "    ")
+		if err != nil {
+			fmt.Fprintf(ctx.Output, "JSON encoding error: %v\n", err)
+			return
+		}
+		ctx.Output.Write(append(data, '\n'))
+		return
+	}
+
+	ctx.buffer = bytes.NewBufferString("")
+	ctx.preformat()
+
+	tmpl, err := ctx.parseFormat()
+	if err != nil {
+		return
+	}
+
+	for _, p := range ctx.Projects {
+		projectCtx := &composeProjectContext{
+			p: p,
+		}
+		err = ctx.contextFormat(tmpl, projectCtx)
+		if err != nil {
+			return
+		}
+	}
+
+	ctx.postformat(tmpl, &composeProjectContext{})
+}
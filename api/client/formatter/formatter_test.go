@@ -2,6 +2,7 @@ package formatter
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
@@ -533,3 +534,196 @@ func TestImageContextWriteWithNoImage(t *testing.T) {
 		out.Reset()
 	}
 }
+
+func TestSnapshotContextWrite(t *testing.T) {
+	out := bytes.NewBufferString("")
+	snapshots := []*types.Snapshot{
+		{Name: "db-auto-1", Volume: "db", Size: 10, Created: time.Now().Add(-2 * time.Hour).Unix()},
+		{Name: "manual", Volume: "db", Size: 20},
+	}
+
+	contexts := []struct {
+		context  SnapshotContext
+		expected string
+	}{
+		{
+			SnapshotContext{
+				Context: Context{
+					Format: "table",
+					Output: out,
+				},
+			},
+			`NAME                VOLUME              SIZE                CREATED
+db-auto-1           db                  10 GB               2 hours ago
+manual              db                  20 GB               
+`,
+		},
+		{
+			SnapshotContext{
+				Context: Context{
+					Format: "table",
+					Output: out,
+					Quiet:  true,
+				},
+			},
+			"db-auto-1\nmanual\n",
+		},
+		{
+			SnapshotContext{
+				Context: Context{
+					Format: "{{.Name}}:{{.Size}}",
+					Output: out,
+				},
+			},
+			"db-auto-1:10 GB\nmanual:20 GB\n",
+		},
+	}
+
+	for _, context := range contexts {
+		context.context.Snapshots = snapshots
+		context.context.Write()
+		actual := out.String()
+		if actual != context.expected {
+			t.Fatalf("Expected \n%s, got \n%s", context.expected, actual)
+		}
+		// Clean buffer
+		out.Reset()
+	}
+}
+
+func TestVolumeContextWriteWithRegions(t *testing.T) {
+	out := bytes.NewBufferString("")
+	volumes := []*types.Volume{
+		{Name: "db", Driver: "hyper", Labels: map[string]string{"size": "10"}},
+		{Name: "db", Driver: "hyper", Labels: map[string]string{"size": "20", "container": "mysql"}},
+	}
+
+	contexts := []struct {
+		context  VolumeContext
+		expected string
+	}{
+		{
+			VolumeContext{
+				Context: Context{
+					Format: "table",
+					Output: out,
+				},
+				Regions: []string{"us-west-1", "eu-central-1"},
+			},
+			`REGION              DRIVER              NAME                SIZE                CONTAINER
+us-west-1           hyper               db                  10 GB               
+eu-central-1        hyper               db                  20 GB               mysql
+`,
+		},
+		{
+			VolumeContext{
+				Context: Context{
+					Format: "table",
+					Output: out,
+					Quiet:  true,
+				},
+				Regions: []string{"us-west-1", "eu-central-1"},
+			},
+			"db\ndb\n",
+		},
+		{
+			VolumeContext{
+				Context: Context{
+					Format: "table {{.Name}}\t{{.Region}}",
+					Output: out,
+				},
+				Regions: []string{"us-west-1", "eu-central-1"},
+			},
+			`NAME                REGION
+db                  us-west-1
+db                  eu-central-1
+`,
+		},
+		{
+			VolumeContext{
+				Context: Context{
+					Format: "table {{.Name}}",
+					Output: out,
+				},
+			},
+			"NAME\ndb\ndb\n",
+		},
+	}
+
+	for _, context := range contexts {
+		context.context.Volumes = volumes
+		context.context.Write()
+		actual := out.String()
+		if actual != context.expected {
+			t.Fatalf("Expected \n%s, got \n%s", context.expected, actual)
+		}
+		// Clean buffer
+		out.Reset()
+	}
+}
+
+func TestComposeProjectContextWrite(t *testing.T) {
+	out := bytes.NewBufferString("")
+	projects := []ComposeProject{
+		{Name: "blog", Containers: map[string]int{"running": 1}, ConfigHash: "0123456789ab", Updated: time.Now().Add(-2 * time.Hour)},
+		{Name: "shop", Containers: map[string]int{"running": 2, "exited": 1}, Services: 1, Volumes: 2, Fips: 1},
+	}
+
+	contexts := []struct {
+		context  ComposeProjectContext
+		expected string
+	}{
+		{
+			ComposeProjectContext{
+				Context: Context{
+					Format: "table",
+					Output: out,
+				},
+			},
+			`NAME                STATUS                  SERVICES            VOLUMES             FIPS                CONFIG HASH         UPDATED
+blog                running(1)              0                   0                   0                   0123456789ab        2 hours ago
+shop                exited(1), running(2)   1                   2                   1                                       
+`,
+		},
+		{
+			ComposeProjectContext{
+				Context: Context{
+					Format: "table",
+					Output: out,
+					Quiet:  true,
+				},
+			},
+			"blog\nshop\n",
+		},
+		{
+			ComposeProjectContext{
+				Context: Context{
+					Format: "{{.Name}}:{{.Status}}",
+					Output: out,
+				},
+			},
+			"blog:running(1)\nshop:exited(1), running(2)\n",
+		},
+	}
+
+	for _, context := range contexts {
+		context.context.Projects = projects
+		context.context.Write()
+		actual := out.String()
+		if actual != context.expected {
+			t.Fatalf("Expected \n%s, got \n%s", context.expected, actual)
+		}
+		// Clean buffer
+		out.Reset()
+	}
+
+	ctx := ComposeProjectContext{Context: Context{Format: "json", Output: out}, Projects: projects[1:]}
+	ctx.Write()
+	var decoded []ComposeProject
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 || decoded[0].Name != "shop" || decoded[0].Containers["running"] != 2 {
+		t.Fatalf("Unexpected JSON output %s", out.String())
+	}
+}
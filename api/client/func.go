@@ -13,6 +13,7 @@ import (
 
 	"github.com/docker/go-connections/nat"
 	units "github.com/docker/go-units"
+	"github.com/hyperhq/hyper-api/client"
 	"github.com/hyperhq/hyper-api/types"
 	"github.com/hyperhq/hyper-api/types/container"
 	"github.com/hyperhq/hyper-api/types/filters"
@@ -316,6 +317,7 @@ func (cli *DockerCli) CmdFuncLs(args ...string) error {
 
 	flFilter := ropts.NewListOpts(nil)
 	cmd.Var(&flFilter, []string{"f", "-filter"}, "Filter output based on conditions provided")
+	flRegion := addRegionFlags(cmd)
 
 	cmd.Require(flag.Exact, 0)
 	err := cmd.ParseFlags(args, true)
@@ -336,17 +338,28 @@ func (cli *DockerCli) CmdFuncLs(args ...string) error {
 		Filters: funcFilterArgs,
 	}
 
-	fns, err := cli.client.FuncList(context.Background(), options)
+	regions, err := flRegion.list()
+	if err != nil {
+		return err
+	}
+	lists := make([][]types.Func, regionCount(regions))
+	err = cli.forEachRegion(regions, func(i int, region string, c client.APIClient) error {
+		fns, err := c.FuncList(context.Background(), options)
+		lists[i] = fns
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
 	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
-	fmt.Fprintf(w, "NAME\tSIZE\tIMAGE\tCOMMAND\tCREATED\tUUID\n")
-	for _, fn := range fns {
-		created := units.HumanDuration(time.Now().UTC().Sub(fn.Created)) + " ago"
-		command := strings.Join([]string(fn.Config.Cmd), " ")
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", fn.Name, fn.ContainerSize, fn.Config.Image, command, created, fn.UUID)
+	fmt.Fprintf(w, "%sNAME\tSIZE\tIMAGE\tCOMMAND\tCREATED\tUUID\n", regionColumn(regions, -1))
+	for i, fns := range lists {
+		for _, fn := range fns {
+			created := units.HumanDuration(time.Now().UTC().Sub(fn.Created)) + " ago"
+			command := strings.Join([]string(fn.Config.Cmd), " ")
+			fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\t%s\n", regionColumn(regions, i), fn.Name, fn.ContainerSize, fn.Config.Image, command, created, fn.UUID)
+		}
 	}
 	w.Flush()
 
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"github.com/hyperhq/hyper-api/client"
 	"github.com/hyperhq/hyper-api/types"
 	"github.com/hyperhq/hyper-api/types/filters"
 	"github.com/hyperhq/hypercli/api/client/formatter"
@@ -20,6 +21,7 @@ func (cli *DockerCli) CmdImages(args ...string) error {
 	noTrunc := cmd.Bool([]string{"-no-trunc"}, false, "Don't truncate output")
 	showDigests := cmd.Bool([]string{"-digests"}, false, "Show digests")
 	format := cmd.String([]string{"-format"}, "", "Pretty-print images using a Go template")
+	flRegion := addRegionFlags(cmd)
 
 	flFilter := opts.NewListOpts(nil)
 	cmd.Var(&flFilter, []string{"f", "-filter"}, "Filter output based on conditions provided")
@@ -49,10 +51,27 @@ func (cli *DockerCli) CmdImages(args ...string) error {
 		Filters:   imageFilterArgs,
 	}
 
-	images, err := cli.client.ImageList(context.Background(), options)
+	regions, err := flRegion.list()
 	if err != nil {
 		return err
 	}
+	lists := make([][]types.Image, regionCount(regions))
+	err = cli.forEachRegion(regions, func(i int, region string, c client.APIClient) error {
+		images, err := c.ImageList(context.Background(), options)
+		lists[i] = images
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	var (
+		images  []types.Image
+		lengths []int
+	)
+	for _, list := range lists {
+		images = append(images, list...)
+		lengths = append(lengths, len(list))
+	}
 
 	f := *format
 	if len(f) == 0 {
@@ -70,8 +89,9 @@ func (cli *DockerCli) CmdImages(args ...string) error {
 			Quiet:  *quiet,
 			Trunc:  !*noTrunc,
 		},
-		Digest: *showDigests,
-		Images: images,
+		Digest:  *showDigests,
+		Images:  images,
+		Regions: listingRegions(regions, lengths),
 	}
 
 	imagesCtx.Write()
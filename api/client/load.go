@@ -1,7 +1,6 @@
 package client
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -13,7 +12,7 @@ import (
 	"github.com/hyperhq/hyper-api/types"
 	Cli "github.com/hyperhq/hypercli/cli"
 	"github.com/hyperhq/hypercli/image"
-	"github.com/hyperhq/hypercli/pkg/archive"
+	"github.com/hyperhq/hypercli/opts"
 	"github.com/hyperhq/hypercli/pkg/jsonmessage"
 	flag "github.com/hyperhq/hypercli/pkg/mflag"
 	"github.com/hyperhq/hypercli/pkg/progress"
@@ -32,71 +31,30 @@ type manifestItem struct {
 	Layers   []string
 }
 
-type readCloser struct {
-	io.Reader
-	NeedClose io.ReadCloser
+// imageLoadOptions controls how a local image is loaded.
+type imageLoadOptions struct {
+	// tags replace the repo tags of the image, if any.
+	tags []string
 }
 
-func (rc readCloser) Close() error {
-	return rc.NeedClose.Close()
+// imageLayer is a layer of an image tar.
+type imageLayer struct {
+	diffID string
+	path   string
 }
 
 func safePath(base, path string) (string, error) {
 	return symlink.FollowSymlinkInScope(filepath.Join(base, path), base)
 }
 
-func removeExistLayers(tmpDir string, existLayers []string, layerPaths []string) error {
-	keepLayerPaths := make(map[string]bool)
-	for _, _layerPath := range layerPaths[len(existLayers):] {
-		layerPath := filepath.Join(tmpDir, _layerPath)
-		info, err := os.Lstat(layerPath)
-		if err != nil {
-			return err
-		}
-		if info.Mode()&os.ModeSymlink == os.ModeSymlink {
-			if _realPath, err := filepath.EvalSymlinks(layerPath); err == nil {
-				realPath := filepath.Join(filepath.Base(filepath.Dir(_realPath)), "layer.tar")
-				keepLayerPaths[realPath] = true
-			}
-		}
-	}
-	for idx := range existLayers {
-		layerPath := layerPaths[idx]
-		if _, ok := keepLayerPaths[layerPath]; !ok {
-			if err := os.Remove(filepath.Join(tmpDir, layerPath)); err != nil {
-				continue
-			}
-		}
-	}
-	return nil
-}
-
-func (cli *DockerCli) getExistLayers(ctx context.Context, tmpDir string) ([]string, []string, error) {
-	manifestPath, err := safePath(tmpDir, "manifest.json")
-	if err != nil {
-		return nil, nil, err
-	}
-
-	manifestFile, err := os.Open(manifestPath)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer manifestFile.Close()
-
-	var manifest []manifestItem
-	if err := json.NewDecoder(manifestFile).Decode(&manifest); err != nil {
-		return nil, nil, err
-	}
-
+// getExistLayers returns the layers of the images of manifest and the diff
+// IDs of the layers the server already has.
+func (cli *DockerCli) getExistLayers(ctx context.Context, src imageSource, manifest []manifestItem) ([]imageLayer, []string, error) {
 	allLayers := make([][]string, 0)
 	repoTags := make([][]string, 0)
-	layerPaths := make([]string, 0)
+	imageLayers := make([]imageLayer, 0)
 	for _, m := range manifest {
-		configPath, err := safePath(tmpDir, m.Config)
-		if err != nil {
-			return nil, nil, err
-		}
-		config, err := ioutil.ReadFile(configPath)
+		config, err := readImageFile(src, m.Config)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -109,12 +67,11 @@ func (cli *DockerCli) getExistLayers(ctx context.Context, tmpDir string) ([]stri
 			return nil, nil, errors.New(unsupported)
 		}
 
-		layerPaths = append(layerPaths, m.Layers...)
-
 		layers := make([]string, 0)
 
-		for _, diffID := range img.RootFS.DiffIDs {
+		for i, diffID := range img.RootFS.DiffIDs {
 			layers = append(layers, string(diffID))
+			imageLayers = append(imageLayers, imageLayer{diffID: string(diffID), path: m.Layers[i]})
 		}
 
 		allLayers = append(allLayers, layers)
@@ -124,89 +81,93 @@ func (cli *DockerCli) getExistLayers(ctx context.Context, tmpDir string) ([]stri
 	if err != nil {
 		return nil, nil, err
 	}
-	return diffRet.ExistLayers, layerPaths, nil
+	return imageLayers, diffRet.ExistLayers, nil
 }
 
-func (cli *DockerCli) ImageLoadFromTar(ctx context.Context, tr io.Reader, quiet bool) (*types.ImageLoadResponse, error) {
-	tmpDir, err := ioutil.TempDir("", "hyper-pull-local-")
-	if err != nil {
-		return nil, err
-	}
-	defer os.RemoveAll(tmpDir)
+// readCloser reads from Reader and closes NeedClose.
+type readCloser struct {
+	io.Reader
+	NeedClose io.ReadCloser
+}
 
-	if err := archive.Untar(tr, tmpDir, &archive.TarOptions{NoLchown: true}); err != nil {
+func (rc readCloser) Close() error {
+	return rc.NeedClose.Close()
+}
+
+// ImageLoadFromTar uploads the layers of an image tar or OCI image layout
+// missing on the server along with its images, in a single stream.
+func (cli *DockerCli) ImageLoadFromTar(ctx context.Context, tr io.Reader, quiet bool, opts *imageLoadOptions) (*types.ImageLoadResponse, error) {
+	src, err := newImageSource(tr)
+	if err != nil {
 		return nil, err
 	}
+	defer src.Close()
 
 	if !quiet {
 		fmt.Fprintln(cli.out, "Diffing local image with remote image...")
 	}
 
-	existLayers, layerPaths, err := cli.getExistLayers(ctx, tmpDir)
+	manifest, err := readImageManifest(src)
 	if err != nil {
 		return nil, err
 	}
-
-	if err := removeExistLayers(tmpDir, existLayers, layerPaths); err != nil {
+	if err := overrideRepoTags(manifest, opts.tags); err != nil {
 		return nil, err
 	}
-
-	fs, err := archive.Tar(tmpDir, archive.Gzip)
+	layers, existLayers, err := cli.getExistLayers(ctx, src, manifest)
 	if err != nil {
 		return nil, err
 	}
-	defer fs.Close()
 
-	hasNewLayers := len(existLayers) != len(layerPaths)
-	if hasNewLayers {
-		if !quiet {
-			fmt.Fprintln(cli.out, "Preparing to upload image...")
-		}
+	// remove the layers the server already has from the image
+	exist := make(map[string]bool)
+	for _, diffID := range existLayers {
+		exist[diffID] = true
 	}
-
-	tarTmpDir, err := ioutil.TempDir("", "hyper-pull-local-")
-	if err != nil {
-		return nil, err
+	var missing []imageLayer
+	for _, layer := range layers {
+		if !exist[layer.diffID] {
+			missing = append(missing, layer)
+		}
 	}
-	defer os.RemoveAll(tarTmpDir)
 
-	tarPath := filepath.Join(tarTmpDir, "image.tar")
-
-	tf, err := os.Create(tarPath)
+	tf, err := ioutil.TempFile("", "hyper-pull-local-")
 	if err != nil {
 		return nil, err
 	}
-	defer tf.Close()
-
-	_, err = io.Copy(tf, fs)
-	if err != nil {
+	defer os.Remove(tf.Name())
+	if err := writeImageArchive(tf, src, manifest, missing); err != nil {
+		tf.Close()
 		return nil, err
 	}
-	os.RemoveAll(tmpDir)
-
 	info, err := tf.Stat()
 	if err != nil {
+		tf.Close()
 		return nil, err
 	}
-	tf, err = os.Open(tarPath)
-	if err != nil {
+	if _, err := tf.Seek(0, os.SEEK_SET); err != nil {
+		tf.Close()
 		return nil, err
 	}
 
+	hasNewLayers := len(missing) > 0
+	if hasNewLayers && !quiet {
+		fmt.Fprintln(cli.out, "Preparing to upload image...")
+	}
+
 	resp, err := cli.client.ImageLoadLocal(ctx, quiet, info.Size())
 	if err != nil {
+		tf.Close()
 		return nil, err
 	}
 
 	if !hasNewLayers || quiet {
 		go func() {
-			_, err := io.Copy(resp.Conn, tf)
-			if err != nil {
+			defer tf.Close()
+			if _, err := io.Copy(resp.Conn, tf); err != nil {
 				fmt.Fprintln(cli.out, err.Error())
 				resp.Conn.Close()
-				return
 			}
-			tf.Close()
 		}()
 		return &types.ImageLoadResponse{
 			Body: resp.Conn,
@@ -219,8 +180,8 @@ func (cli *DockerCli) ImageLoadFromTar(ctx context.Context, tr io.Reader, quiet
 	progressReader := progress.NewProgressReader(tf, progressOutput, info.Size(), "", "Uploading image")
 
 	go func() {
-		_, err := io.Copy(resp.Conn, progressReader)
-		if err != nil {
+		defer tf.Close()
+		if _, err := io.Copy(resp.Conn, progressReader); err != nil {
 			fmt.Fprintln(cli.out, err.Error())
 			resp.Conn.Close()
 			return
@@ -235,7 +196,7 @@ func (cli *DockerCli) ImageLoadFromTar(ctx context.Context, tr io.Reader, quiet
 }
 
 // ImageDiff diff an image layers with local and imaged
-func (cli *DockerCli) ImageLoadFromDaemon(ctx context.Context, name string, quiet bool) (*types.ImageLoadResponse, error) {
+func (cli *DockerCli) ImageLoadFromDaemon(ctx context.Context, name string, quiet bool, opts *imageLoadOptions) (*types.ImageLoadResponse, error) {
 	if !quiet {
 		fmt.Fprintln(cli.out, "Loading image from local docker daemon...")
 	}
@@ -246,7 +207,7 @@ func (cli *DockerCli) ImageLoadFromDaemon(ctx context.Context, name string, quie
 	}
 	defer tr.Close()
 
-	return cli.ImageLoadFromTar(ctx, tr, quiet)
+	return cli.ImageLoadFromTar(ctx, tr, quiet, opts)
 }
 
 // CmdLoad load a local image or a tar file
@@ -257,11 +218,15 @@ func (cli *DockerCli) ImageLoadFromDaemon(ctx context.Context, name string, quie
 func (cli *DockerCli) CmdLoad(args ...string) error {
 	cmd := Cli.Subcmd("load", nil, "Load a local image or a tar file", true)
 	local := cmd.String([]string{"l", "-local"}, "", "Read from a local image")
-	infile := cmd.String([]string{"i", "-input"}, "", "Read from a local or remote archive file compressed with gzip, bzip, or xz, instead of STDIN")
+	infile := cmd.String([]string{"i", "-input"}, "", "Read from a local or remote archive file compressed with gzip, bzip, or xz, or from an OCI image layout directory, instead of STDIN")
 	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Do not show load process")
+	flTags := opts.NewListOpts(nil)
+	cmd.Var(&flTags, []string{"t", "-tag"}, "Name and optionally a tag in the 'name:tag' format for the loaded image")
 	cmd.Require(flag.Exact, 0)
 	cmd.ParseFlags(args, true)
 
+	loadOpts := &imageLoadOptions{tags: flTags.GetAll()}
+
 	*infile = strings.TrimSpace(*infile)
 	*local = strings.TrimSpace(*local)
 
@@ -276,11 +241,14 @@ func (cli *DockerCli) CmdLoad(args ...string) error {
 
 	if *local != "" {
 		// Load from local docker daemon
-		response, err = cli.ImageLoadFromDaemon(context.Background(), *local, *quiet)
+		response, err = cli.ImageLoadFromDaemon(context.Background(), *local, *quiet, loadOpts)
 	} else if *infile != "" {
 		if strings.HasPrefix(*infile, "http://") ||
 			strings.HasPrefix(*infile, "https://") ||
 			strings.HasPrefix(*infile, "ftp://") {
+			if flTags.Len() > 0 {
+				return errors.New("--tag can't be used to load a remote archive")
+			}
 			var input struct {
 				FromSrc string `json:"fromSrc"`
 				Quiet   bool   `json:"quiet"`
@@ -297,11 +265,11 @@ func (cli *DockerCli) CmdLoad(args ...string) error {
 				return err
 			}
 			defer af.Close()
-			response, err = cli.ImageLoadFromTar(context.Background(), af, *quiet)
+			response, err = cli.ImageLoadFromTar(context.Background(), af, *quiet, loadOpts)
 		}
 	} else if stdin != nil {
 		// Load from STDIN
-		response, err = cli.ImageLoadFromTar(context.Background(), stdin, *quiet)
+		response, err = cli.ImageLoadFromTar(context.Background(), stdin, *quiet, loadOpts)
 	}
 
 	if err != nil {
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperhq/hypercli/reference"
+)
+
+const (
+	ociIndexMediaType         = "application/vnd.oci.image.index.v1+json"
+	ociManifestMediaType      = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestListType    = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerManifestMediaType   = "application/vnd.docker.distribution.manifest.v2+json"
+	ociRefNameAnnotation      = "org.opencontainers.image.ref.name"
+	containerdImageAnnotation = "io.containerd.image.name"
+
+	// maxOCIIndexDepth is the number of nested indexes followed to find
+	// the manifest of an image.
+	maxOCIIndexDepth = 4
+)
+
+// ociLayerMediaTypes are the layers that can be loaded, as is.
+var ociLayerMediaTypes = map[string]bool{
+	"application/vnd.oci.image.layer.v1.tar":                    true,
+	"application/vnd.oci.image.layer.v1.tar+gzip":               true,
+	"application/vnd.docker.image.rootfs.diff.tar.gzip":         true,
+	"application/vnd.oci.image.layer.nondistributable.v1.tar":   true,
+	"application/vnd.docker.image.rootfs.foreign.diff.tar.gzip": true,
+}
+
+// ociDescriptor references a blob of an OCI image layout.
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+// ociIndex is the index.json of an OCI image layout, or a nested index
+// of the images of several platforms.
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+// ociManifest is the manifest of an image of an OCI image layout.
+type ociManifest struct {
+	Config ociDescriptor   `json:"config"`
+	Layers []ociDescriptor `json:"layers"`
+}
+
+// readImageManifest returns the images of a `docker save` tar, or of an
+// OCI image layout, as directory or tar.
+func readImageManifest(src imageSource) ([]manifestItem, error) {
+	if data, err := readImageFile(src, "manifest.json"); err == nil {
+		var manifest []manifestItem
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, err
+		}
+		return manifest, nil
+	}
+	data, err := readImageFile(src, "index.json")
+	if err != nil {
+		return nil, fmt.Errorf("no manifest.json or index.json found, the archive is neither a docker image nor an OCI image layout")
+	}
+	var index ociIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	var manifest []manifestItem
+	for _, desc := range index.Manifests {
+		item, err := ociManifestItem(src, desc, 0)
+		if err != nil {
+			return nil, err
+		}
+		item.RepoTags = ociRepoTags(desc)
+		manifest = append(manifest, item)
+	}
+	if len(manifest) == 0 {
+		return nil, fmt.Errorf("no image found in index.json")
+	}
+	return manifest, nil
+}
+
+// ociBlobPath returns the path of a blob in an OCI image layout.
+func ociBlobPath(digest string) (string, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.ContainsAny(digest, "/\\") {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return "blobs/" + parts[0] + "/" + parts[1], nil
+}
+
+// ociManifestItem maps the manifest of an OCI image onto the manifest of
+// a `docker save` tar. For indexes of several platforms, the linux/amd64
+// image is used.
+func ociManifestItem(src imageSource, desc ociDescriptor, depth int) (manifestItem, error) {
+	blob, err := ociBlobPath(desc.Digest)
+	if err != nil {
+		return manifestItem{}, err
+	}
+	data, err := readImageFile(src, blob)
+	if err != nil {
+		return manifestItem{}, err
+	}
+
+	switch desc.MediaType {
+	case ociIndexMediaType, dockerManifestListType:
+		if depth >= maxOCIIndexDepth {
+			return manifestItem{}, fmt.Errorf("too many nested indexes in %s", desc.Digest)
+		}
+		var index ociIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			return manifestItem{}, err
+		}
+		for _, m := range index.Manifests {
+			if m.Platform == nil || (m.Platform.OS == "linux" && m.Platform.Architecture == "amd64") {
+				return ociManifestItem(src, m, depth+1)
+			}
+		}
+		return manifestItem{}, fmt.Errorf("no linux/amd64 image found in %s", desc.Digest)
+	case ociManifestMediaType, dockerManifestMediaType, "":
+		var manifest ociManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return manifestItem{}, err
+		}
+		item := manifestItem{}
+		if item.Config, err = ociBlobPath(manifest.Config.Digest); err != nil {
+			return manifestItem{}, err
+		}
+		for _, layer := range manifest.Layers {
+			if !ociLayerMediaTypes[layer.MediaType] {
+				return manifestItem{}, fmt.Errorf("unsupported layer media type %s", layer.MediaType)
+			}
+			path, err := ociBlobPath(layer.Digest)
+			if err != nil {
+				return manifestItem{}, err
+			}
+			item.Layers = append(item.Layers, path)
+		}
+		return item, nil
+	default:
+		return manifestItem{}, fmt.Errorf("unsupported manifest media type %s", desc.MediaType)
+	}
+}
+
+// ociRepoTags returns the tag of an image given by the annotations of its
+// descriptor in index.json. A bare tag, without repository, is ignored.
+func ociRepoTags(desc ociDescriptor) []string {
+	for _, key := range []string{containerdImageAnnotation, ociRefNameAnnotation} {
+		name := desc.Annotations[key]
+		if name == "" {
+			continue
+		}
+		if ref, err := reference.ParseNamed(name); err == nil && strings.ContainsAny(name, "/:") {
+			return []string{reference.WithDefaultTag(ref).String()}
+		}
+	}
+	return nil
+}
+
+// overrideRepoTags replaces the tags of the image of manifest, for
+// `load --tag`.
+func overrideRepoTags(manifest []manifestItem, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	if len(manifest) != 1 {
+		return fmt.Errorf("--tag can only be used with an archive holding a single image, got %d", len(manifest))
+	}
+	repoTags := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		ref, err := reference.ParseNamed(tag)
+		if err != nil {
+			return err
+		}
+		if _, ok := ref.(reference.Canonical); ok {
+			return fmt.Errorf("invalid tag %s: a digest can't be used as a tag", tag)
+		}
+		repoTags = append(repoTags, reference.WithDefaultTag(ref).String())
+	}
+	manifest[0].RepoTags = repoTags
+	return nil
+}
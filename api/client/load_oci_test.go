@@ -0,0 +1,90 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeBlob writes a blob to an OCI image layout and returns its digest.
+func writeBlob(t *testing.T, dir, content string) string {
+	sum := sha256.Sum256([]byte(content))
+	hex := hex.EncodeToString(sum[:])
+	if err := ioutil.WriteFile(filepath.Join(dir, "blobs", "sha256", hex), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return "sha256:" + hex
+}
+
+func TestReadOCIImageManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-layout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	config := writeBlob(t, dir, `{"rootfs":{"type":"layers","diff_ids":["sha256:aaa"]}}`)
+	layer := writeBlob(t, dir, "layer")
+	manifest := writeBlob(t, dir, `{"config":{"digest":"`+config+`"},"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":"`+layer+`"}]}`)
+	index := writeBlob(t, dir, `{"manifests":[
+		{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:000","platform":{"os":"linux","architecture":"arm64"}},
+		{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"`+manifest+`","platform":{"os":"linux","architecture":"amd64"}}]}`)
+	ioutil.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "index.json"), []byte(`{"manifests":[
+		{"mediaType":"application/vnd.oci.image.index.v1+json","digest":"`+index+`","annotations":{"org.opencontainers.image.ref.name":"registry.example.com/app:1.0"}}]}`), 0644)
+
+	f, err := os.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	src, err := newImageSource(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := readImageManifest(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []manifestItem{{
+		Config:   "blobs/sha256/" + config[7:],
+		RepoTags: []string{"registry.example.com/app:1.0"},
+		Layers:   []string{"blobs/sha256/" + layer[7:]},
+	}}
+	if !reflect.DeepEqual(items, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, items)
+	}
+
+	if err := overrideRepoTags(items, []string{"app", "app:ci"}); err != nil {
+		t.Fatal(err)
+	}
+	if tags := items[0].RepoTags; !reflect.DeepEqual(tags, []string{"app:latest", "app:ci"}) {
+		t.Fatalf("Unexpected tags %v", tags)
+	}
+
+	src.Close()
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("Expected the image layout to be kept, got %v", err)
+	}
+}
+
+func TestOCIRepoTags(t *testing.T) {
+	desc := ociDescriptor{Annotations: map[string]string{ociRefNameAnnotation: "latest"}}
+	if tags := ociRepoTags(desc); tags != nil {
+		t.Fatalf("Expected a bare tag to be ignored, got %v", tags)
+	}
+	desc.Annotations[containerdImageAnnotation] = "docker.io/library/busybox:1.24"
+	if tags := ociRepoTags(desc); !reflect.DeepEqual(tags, []string{"busybox:1.24"}) {
+		t.Fatalf("Unexpected tags %v", tags)
+	}
+	if err := overrideRepoTags([]manifestItem{{}, {}}, []string{"app"}); err == nil {
+		t.Fatal("Expected an error for --tag with several images")
+	}
+}
@@ -0,0 +1,276 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/hyperhq/hypercli/pkg/archive"
+)
+
+// maxImageTarLinks is the number of links followed to open a file of an
+// image tar, `docker save` links layers shared by several images.
+const maxImageTarLinks = 10
+
+// imageSource gives access to the files of an image tar, as written by
+// `docker save`, or of an OCI image layout.
+type imageSource interface {
+	// open returns a file of the image tar and its size.
+	open(name string) (io.ReadCloser, int64, error)
+	Close() error
+}
+
+// newImageSource reads an image tar in place if it is an uncompressed
+// regular file, or else extracts it to a temporary directory. Directories,
+// like OCI image layouts, are read as they are.
+func newImageSource(r io.Reader) (imageSource, error) {
+	if f, ok := r.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.IsDir() {
+			return dirImageSource{dir: f.Name()}, nil
+		}
+		if src, err := indexImageTar(f); err != nil || src != nil {
+			return src, err
+		}
+	}
+	tmpDir, err := ioutil.TempDir("", "hyper-pull-local-")
+	if err != nil {
+		return nil, err
+	}
+	if err := archive.Untar(r, tmpDir, &archive.TarOptions{NoLchown: true}); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	return dirImageSource{dir: tmpDir, temporary: true}, nil
+}
+
+// tarEntry is a file of an image tar read in place.
+type tarEntry struct {
+	size int64
+	// offset is the position of the content of the file in the tar.
+	offset int64
+	// link is the file the entry links to, if any.
+	link string
+}
+
+// tarImageSource reads the files of an uncompressed image tar file in
+// place, without copying them.
+type tarImageSource struct {
+	f       *os.File
+	entries map[string]tarEntry
+}
+
+// positionReader tracks the position of a reader, the tar reader seeks
+// over the content of the files it skips.
+type positionReader struct {
+	r   io.ReadSeeker
+	pos int64
+}
+
+func (r *positionReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.pos += int64(n)
+	return n, err
+}
+
+func (r *positionReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.r.Seek(offset, whence)
+	if err == nil {
+		r.pos = pos
+	}
+	return pos, err
+}
+
+// indexImageTar lists the files of an image tar file. It returns a nil
+// source if f can't be read in place, because it is compressed or not
+// seekable.
+func indexImageTar(f *os.File) (imageSource, error) {
+	start, err := f.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		// a pipe
+		return nil, nil
+	}
+	magic := make([]byte, 10)
+	n, err := f.ReadAt(magic, start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if archive.DetectCompression(magic[:n]) != archive.Uncompressed {
+		return nil, nil
+	}
+
+	src := &tarImageSource{f: f, entries: make(map[string]tarEntry)}
+	pr := &positionReader{r: f, pos: start}
+	tr := tar.NewReader(pr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := cleanTarPath(hdr.Name)
+		entry := tarEntry{size: hdr.Size, offset: pr.pos}
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			entry.link = cleanTarPath(path.Join(path.Dir(name), hdr.Linkname))
+		case tar.TypeLink:
+			entry.link = cleanTarPath(hdr.Linkname)
+		}
+		src.entries[name] = entry
+	}
+	if _, err := f.Seek(start, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+// cleanTarPath returns the path of a file of a tar relative to its root.
+func cleanTarPath(name string) string {
+	name = path.Clean("/" + name)
+	return name[1:]
+}
+
+func (s *tarImageSource) open(name string) (io.ReadCloser, int64, error) {
+	name = cleanTarPath(name)
+	for i := 0; i < maxImageTarLinks; i++ {
+		entry, ok := s.entries[name]
+		if !ok {
+			return nil, 0, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		if entry.link != "" {
+			name = entry.link
+			continue
+		}
+		size := entry.size
+		return ioutil.NopCloser(io.NewSectionReader(s.f, entry.offset, size)), size, nil
+	}
+	return nil, 0, fmt.Errorf("too many links to %s in the image archive", name)
+}
+
+func (s *tarImageSource) Close() error {
+	return nil
+}
+
+// dirImageSource reads the files of an image directory, or of an image
+// tar extracted to a temporary directory removed on Close.
+type dirImageSource struct {
+	dir       string
+	temporary bool
+}
+
+func (s dirImageSource) open(name string) (io.ReadCloser, int64, error) {
+	p, err := safePath(s.dir, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+func (s dirImageSource) Close() error {
+	if !s.temporary {
+		return nil
+	}
+	return os.RemoveAll(s.dir)
+}
+
+// readImageFile returns the content of a file of an image.
+func readImageFile(src imageSource, name string) ([]byte, error) {
+	r, _, err := src.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// writeImageArchive writes a gzip'd `docker save` tar of the images of
+// manifest with only the given layers, the server having the others. The
+// tar always holds the manifest, the configs of the images, and the
+// repositories file and the json and VERSION files of every layer if the
+// image has them.
+func writeImageArchive(w io.Writer, src imageSource, manifest []manifestItem, layers []imageLayer) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	add := func(name string, r io.Reader, size int64) error {
+		hdr := &tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     size,
+			ModTime:  time.Now(),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	}
+	written := make(map[string]bool)
+	// addFile adds a file of src to the tar once, optional files missing
+	// from src are left out
+	addFile := func(file string, optional bool) error {
+		name := cleanTarPath(file)
+		if written[name] {
+			return nil
+		}
+		written[name] = true
+		r, size, err := src.open(file)
+		if optional && os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer r.Close()
+		return add(name, r, size)
+	}
+
+	if err := add("manifest.json", bytes.NewReader(data), int64(len(data))); err != nil {
+		return err
+	}
+	if err := addFile("repositories", true); err != nil {
+		return err
+	}
+	for _, m := range manifest {
+		if err := addFile(m.Config, false); err != nil {
+			return err
+		}
+		for _, layer := range m.Layers {
+			dir := path.Dir(cleanTarPath(layer))
+			for _, file := range []string{"VERSION", "json"} {
+				if err := addFile(path.Join(dir, file), true); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, layer := range layers {
+		if err := addFile(layer.path, false); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
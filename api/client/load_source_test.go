@@ -0,0 +1,152 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// writeImageTar writes a tar like `docker save` with a layer shared
+// through a symlink.
+func writeImageTar(t *testing.T, w io.Writer) {
+	tw := tar.NewWriter(w)
+	files := []struct {
+		name, link, content string
+	}{
+		{name: "manifest.json", content: `[{"Config":"abc.json","Layers":["l1/layer.tar","l2/layer.tar"]}]`},
+		{name: "abc.json", content: `{}`},
+		{name: "l1/layer.tar", content: "layer 1"},
+		{name: "l1/VERSION", content: "1.0"},
+		{name: "l1/json", content: `{"id":"l1"}`},
+		{name: "l2/VERSION", content: "1.0"},
+		{name: "l2/json", content: `{"id":"l2"}`},
+		{name: "repositories", content: `{"app":{"latest":"l2"}}`},
+		{name: "l2/layer.tar", link: "../l1/layer.tar"},
+	}
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.content)), Typeflag: tar.TypeReg}
+		if f.link != "" {
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, f.link, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, src imageSource, name string) string {
+	r, size, err := src.open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(b)) != size {
+		t.Fatalf("Expected %d bytes for %s, got %d", size, name, len(b))
+	}
+	return string(b)
+}
+
+func archiveFiles(t *testing.T, src imageSource, layers []imageLayer) []string {
+	manifest, err := readImageManifest(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := writeImageArchive(buf, src, manifest, layers); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag != tar.TypeDir {
+			names = append(names, hdr.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func testImageSource(t *testing.T, src imageSource) {
+	if content := readFile(t, src, "l1/layer.tar"); content != "layer 1" {
+		t.Fatalf("Unexpected layer %q", content)
+	}
+	if content := readFile(t, src, "l2/layer.tar"); content != "layer 1" {
+		t.Fatalf("Expected the link to the layer to be followed, got %q", content)
+	}
+	names := archiveFiles(t, src, nil)
+	if expected := []string{"abc.json", "l1/VERSION", "l1/json", "l2/VERSION", "l2/json", "manifest.json", "repositories"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected the metadata files %v, got %v", expected, names)
+	}
+	names = archiveFiles(t, src, []imageLayer{{diffID: "sha256:1", path: "l2/layer.tar"}})
+	if expected := []string{"abc.json", "l1/VERSION", "l1/json", "l2/VERSION", "l2/json", "l2/layer.tar", "manifest.json", "repositories"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected the metadata files and the missing layer %v, got %v", expected, names)
+	}
+}
+
+func TestTarImageSource(t *testing.T) {
+	f, err := ioutil.TempFile("", "image-tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	writeImageTar(t, f)
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := newImageSource(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	if _, ok := src.(*tarImageSource); !ok {
+		t.Fatalf("Expected an uncompressed tar file to be read in place, got %T", src)
+	}
+	testImageSource(t, src)
+}
+
+func TestDirImageSource(t *testing.T) {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	writeImageTar(t, gz)
+	gz.Close()
+
+	src, err := newImageSource(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := src.(dirImageSource).dir
+	testImageSource(t, src)
+	src.Close()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("Expected the extracted image to be removed, got %v", err)
+	}
+}
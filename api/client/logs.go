@@ -3,13 +3,22 @@ package client
 import (
 	"fmt"
 	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 
 	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/hyper-api/types/filters"
 	Cli "github.com/hyperhq/hypercli/cli"
+	"github.com/hyperhq/hypercli/opts"
 	flag "github.com/hyperhq/hypercli/pkg/mflag"
 	"github.com/hyperhq/hypercli/pkg/stdcopy"
+	"github.com/hyperhq/libcompose/logger"
 )
 
 var validDrivers = map[string]bool{
@@ -17,49 +26,385 @@ var validDrivers = map[string]bool{
 	"journald":  true,
 }
 
-// CmdLogs fetches the logs of a given container.
+const (
+	// logsPollInterval is how often `logs -f` looks for new containers
+	// matching --filter or --service.
+	logsPollInterval = 2 * time.Second
+	// logsFlushInterval is how often merged log lines are written while
+	// following the logs.
+	logsFlushInterval = 100 * time.Millisecond
+)
+
+// CmdLogs fetches the logs of one or more containers.
+//
+// The logs of several containers, of the containers matching filters or of
+// the containers of a service are merged in timestamp order, each line
+// prefixed with the name of its container. With --output, the logs of each
+// container are written to <name>.stdout and <name>.stderr in a directory
+// instead. With --forward, the logs are followed and shipped to fluentd,
+// syslog or a GELF endpoint.
 //
-// docker logs [OPTIONS] CONTAINER
+// docker logs [OPTIONS] [CONTAINER...]
 func (cli *DockerCli) CmdLogs(args ...string) error {
-	cmd := Cli.Subcmd("logs", []string{"CONTAINER"}, Cli.DockerCommands["logs"].Description, true)
+	cmd := Cli.Subcmd("logs", []string{"[CONTAINER...]"}, Cli.DockerCommands["logs"].Description, true)
 	follow := cmd.Bool([]string{"f", "-follow"}, false, "Follow log output")
 	since := cmd.String([]string{"-since"}, "", "Show logs since timestamp")
 	times := cmd.Bool([]string{"t", "-timestamps"}, false, "Show timestamps")
+	until := cmd.String([]string{"-until"}, "", "Show logs before timestamp")
 	tail := cmd.String([]string{"-tail"}, "all", "Number of lines to show from the end of the logs")
-	cmd.Require(flag.Exact, 1)
+	grep := cmd.String([]string{"-grep"}, "", "Only show the lines matching a regular expression")
+	outputDir := cmd.String([]string{"o", "-output"}, "", "Write the logs of each container to files in a directory")
+	compress := cmd.Bool([]string{"z", "-gzip"}, false, "Compress the files written with --output")
+	forward := cmd.String([]string{"-forward"}, "", "Forward the logs to fluentd://, syslog:// or gelf:// until interrupted")
+	flFilter := opts.NewListOpts(nil)
+	cmd.Var(&flFilter, []string{"-filter"}, "Show the logs of the containers matching a filter (i.e. 'label=<key>=<value>')")
+	flServices := opts.NewListOpts(nil)
+	cmd.Var(&flServices, []string{"-service"}, "Show the logs of the containers of a service")
+	cmd.Require(flag.Min, 0)
 
 	cmd.ParseFlags(args, true)
 
-	name := cmd.Arg(0)
-
-	ctx := context.Background()
-	c, err := cli.client.ContainerInspect(ctx, name)
+	if *compress && *outputDir == "" {
+		return fmt.Errorf("--gzip requires --output")
+	}
+	if *compress && *follow {
+		return fmt.Errorf("--gzip can't be used with --follow")
+	}
+	filter, err := newLogsFilter(*until, *grep)
 	if err != nil {
 		return err
 	}
-
-	if !validDrivers[c.HostConfig.LogConfig.Type] {
-		return fmt.Errorf("\"logs\" command is supported only for \"json-file\" and \"journald\" logging drivers (got: %s)", c.HostConfig.LogConfig.Type)
+	var forwarder *logForwarder
+	if *forward != "" {
+		if *outputDir != "" {
+			return fmt.Errorf("--forward can't be used with --output")
+		}
+		if forwarder, err = newLogForwarder(*forward); err != nil {
+			return err
+		}
+		*follow = true
+		if !cmd.IsSet("-since") && !cmd.IsSet("-tail") {
+			// only ship the lines logged from now on, including those of
+			// the containers attached later
+			*since = strconv.FormatInt(time.Now().Unix(), 10)
+		}
+	}
+	if !filter.until.IsZero() && filter.until.Before(time.Now()) {
+		// no more lines will be logged before --until
+		*follow = false
 	}
 
 	options := types.ContainerLogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
 		Since:      *since,
+		Until:      *until,
 		Timestamps: *times,
 		Follow:     *follow,
 		Tail:       *tail,
 	}
+	ctx := context.Background()
+
+	if cmd.NArg() == 1 && flFilter.Len() == 0 && flServices.Len() == 0 && *outputDir == "" && forwarder == nil {
+		return cli.containerLogs(ctx, cmd.Arg(0), options, filter)
+	}
+	if cmd.NArg() == 0 && flFilter.Len() == 0 && flServices.Len() == 0 {
+		cmd.ReportError("\"logs\" requires a container, --filter or --service", true)
+		os.Exit(1)
+	}
+
+	selector := logsSelector{
+		containers: cmd.Args(),
+		filter:     filters.NewArgs(),
+		services:   flServices.GetAll(),
+	}
+	for _, f := range flFilter.GetAll() {
+		if selector.filter, err = filters.ParseFlag(f, selector.filter); err != nil {
+			return err
+		}
+	}
+
+	if *outputDir != "" {
+		if err := os.MkdirAll(*outputDir, 0755); err != nil {
+			return err
+		}
+	}
+
+	l := &mergedLogs{
+		cli:       cli,
+		options:   options,
+		filter:    filter,
+		outputDir: *outputDir,
+		gzip:      *compress,
+		forwarder: forwarder,
+		factory:   logger.NewColorLoggerFactory(),
+		merger:    &logMerger{},
+		attached:  make(map[string]bool),
+	}
+	ids, err := cli.logsContainers(ctx, selector)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 && !*follow {
+		return fmt.Errorf("No container found")
+	}
+	l.attach(ctx, ids)
+
+	if !*follow {
+		// the logs are complete once all streams are done, so they are
+		// merged at once rather than within a time window
+		l.wg.Wait()
+		l.merger.flush(time.Now())
+		return nil
+	}
+
+	// without --filter or --service no container is attached later, so
+	// the logs are complete once all streams are done
+	var done chan struct{}
+	if flFilter.Len() == 0 && flServices.Len() == 0 {
+		done = make(chan struct{})
+		go func() {
+			l.wg.Wait()
+			close(done)
+		}()
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	flush := time.NewTicker(logsFlushInterval)
+	defer flush.Stop()
+	poll := time.NewTicker(logsPollInterval)
+	defer poll.Stop()
+	for {
+		select {
+		case <-done:
+			l.merger.flush(time.Now())
+			return nil
+		case <-interrupt:
+			// write the lines still waiting to be merged
+			l.merger.flush(time.Now())
+			return nil
+		case <-flush.C:
+			l.merger.flush(time.Now().Add(-logsFlushInterval))
+		case <-poll.C:
+			// attach to the new replicas and matching containers
+			ids, err := cli.logsContainers(ctx, selector)
+			if err != nil {
+				fmt.Fprintf(cli.err, "Error listing containers: %v\n", err)
+				continue
+			}
+			l.attach(ctx, ids)
+		}
+	}
+}
+
+// containerLogs writes the logs of a single container kept by filter.
+func (cli *DockerCli) containerLogs(ctx context.Context, name string, options types.ContainerLogsOptions, filter *logsFilter) error {
+	c, err := cli.client.ContainerInspect(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if !validDrivers[c.HostConfig.LogConfig.Type] {
+		return fmt.Errorf("\"logs\" command is supported only for \"json-file\" and \"journald\" logging drivers (got: %s)", c.HostConfig.LogConfig.Type)
+	}
+
+	keepTimestamps := options.Timestamps
+	if filter.needsTimestamps() {
+		options.Timestamps = true
+	}
 	responseBody, err := cli.client.ContainerLogs(ctx, name, options)
 	if err != nil {
 		return err
 	}
 	defer responseBody.Close()
 
+	if filter.empty() {
+		// write the logs as they are, partial lines included
+		if c.Config.Tty {
+			_, err = io.Copy(cli.out, responseBody)
+		} else {
+			_, err = stdcopy.StdCopy(cli.out, cli.err, responseBody)
+		}
+		return err
+	}
+
+	stdout := filterLogs(cli.out, filter, options.Timestamps, keepTimestamps)
+	stderr := filterLogs(cli.err, filter, options.Timestamps, keepTimestamps)
 	if c.Config.Tty {
-		_, err = io.Copy(cli.out, responseBody)
+		_, err = io.Copy(stdout, responseBody)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, responseBody)
+	}
+	if err == nil {
+		err = stdout.Close()
+	}
+	if err == nil {
+		err = stderr.Close()
+	}
+	if err == errLogsUntil {
+		return nil
+	}
+	return err
+}
+
+// logsSelector selects the containers to show the logs of.
+type logsSelector struct {
+	containers []string
+	filter     filters.Args
+	services   []string
+}
+
+// logsContainers returns the IDs or names of the containers selected by s.
+func (cli *DockerCli) logsContainers(ctx context.Context, s logsSelector) ([]string, error) {
+	ids := append([]string(nil), s.containers...)
+	if s.filter.Len() > 0 {
+		containers, err := cli.client.ContainerList(ctx, types.ContainerListOptions{All: true, Filter: s.filter})
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			ids = append(ids, c.ID)
+		}
+	}
+	for _, name := range s.services {
+		sv, err := cli.client.ServiceInspect(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, sv.Containers...)
+	}
+	return ids, nil
+}
+
+// mergedLogs streams the logs of several containers into a logMerger, or
+// into files in outputDir or through forwarder if set.
+type mergedLogs struct {
+	cli       *DockerCli
+	options   types.ContainerLogsOptions
+	filter    *logsFilter
+	outputDir string
+	gzip      bool
+	forwarder *logForwarder
+	factory   logger.Factory
+	merger    *logMerger
+
+	wg sync.WaitGroup
+	mu sync.Mutex
+	// attached holds the containers whose logs are or were streamed, by
+	// ID and name.
+	attached map[string]bool
+}
+
+// attach starts streaming the logs of the containers not attached yet.
+func (l *mergedLogs) attach(ctx context.Context, ids []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		if l.attached[id] {
+			continue
+		}
+		info, err := l.cli.client.ContainerInspect(ctx, id)
+		if err != nil {
+			fmt.Fprintf(l.cli.err, "Error: %v\n", err)
+			l.attached[id] = true
+			continue
+		}
+		name := info.Name
+		if len(name) > 0 && name[0] == '/' {
+			name = name[1:]
+		}
+		// the same container may be given by ID and by name
+		seen := l.attached[info.ID] || l.attached[name]
+		l.attached[id] = true
+		l.attached[info.ID] = true
+		l.attached[name] = true
+		if seen {
+			continue
+		}
+		if !validDrivers[info.HostConfig.LogConfig.Type] {
+			fmt.Fprintf(l.cli.err, "Error: %s: \"logs\" command is supported only for \"json-file\" and \"journald\" logging drivers (got: %s)\n", name, info.HostConfig.LogConfig.Type)
+			continue
+		}
+
+		lg := l.factory.Create(name)
+		l.wg.Add(1)
+		go func(info types.ContainerJSON, name string) {
+			defer l.wg.Done()
+			if err := l.stream(ctx, info, name, lg); err != nil {
+				lg.Err([]byte(fmt.Sprintf("error reading logs: %v\n", err)))
+			}
+		}(info, name)
+	}
+}
+
+func (l *mergedLogs) stream(ctx context.Context, info types.ContainerJSON, name string, lg logger.Logger) error {
+	options := l.options
+	// timestamps are needed to merge the lines, they are removed again
+	// unless asked for
+	options.Timestamps = true
+	body, err := l.cli.client.ContainerLogs(ctx, info.ID, options)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var stdout, stderr *logLineWriter
+	if l.forwarder != nil {
+		dl, err := l.forwarder.create(info)
+		if err != nil {
+			return err
+		}
+		defer dl.Close()
+		fmt.Fprintf(l.cli.out, "Forwarding the logs of %s\n", name)
+		stdout = forwardLines(dl, info.ID, "stdout", l.filter, l.cli.err)
+		stderr = forwardLines(dl, info.ID, "stderr", l.filter, l.cli.err)
+	} else if l.outputDir != "" {
+		ext := ""
+		if l.gzip {
+			ext = ".gz"
+		}
+		stdoutFile := &logFile{path: filepath.Join(l.outputDir, name+".stdout"+ext), gzip: l.gzip}
+		stderrFile := &logFile{path: filepath.Join(l.outputDir, name+".stderr"+ext), gzip: l.gzip}
+		defer stdoutFile.Close()
+		defer stderrFile.Close()
+		stdout = filterLogs(stdoutFile, l.filter, true, l.options.Timestamps)
+		stderr = filterLogs(stderrFile, l.filter, true, l.options.Timestamps)
+	} else {
+		stdout = l.mergeLines(lg, false)
+		stderr = l.mergeLines(lg, true)
+	}
+	if info.Config.Tty {
+		_, err = io.Copy(stdout, body)
 	} else {
-		_, err = stdcopy.StdCopy(cli.out, cli.err, responseBody)
+		_, err = stdcopy.StdCopy(stdout, stderr, body)
+	}
+	if err == nil {
+		err = stdout.Close()
+	}
+	if err == nil {
+		err = stderr.Close()
+	}
+	if err == errLogsUntil {
+		return nil
 	}
 	return err
 }
+
+// mergeLines returns a logLineWriter adding the lines kept by l.filter to
+// l.merger.
+func (l *mergedLogs) mergeLines(lg logger.Logger, stderr bool) *logLineWriter {
+	return &logLineWriter{line: func(line []byte) error {
+		ts, msg := splitLogTimestamp(line)
+		keep, err := l.filter.match(ts, msg)
+		if err != nil || !keep {
+			return err
+		}
+		if !l.options.Timestamps {
+			line = msg
+		}
+		l.merger.add(lg, stderr, ts, line, time.Now())
+		return nil
+	}}
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	timetypes "github.com/hyperhq/hyper-api/types/time"
+)
+
+// errLogsUntil stops reading logs once a line logged after --until is read.
+var errLogsUntil = errors.New("logs until reached")
+
+// logsFilter selects the log lines to show.
+type logsFilter struct {
+	// until drops the lines logged after it, unless zero.
+	until time.Time
+	// grep keeps only the lines matching it, unless nil.
+	grep *regexp.Regexp
+}
+
+// newLogsFilter parses the --until and --grep options of `hyper logs`.
+func newLogsFilter(until, grep string) (*logsFilter, error) {
+	f := &logsFilter{}
+	if until != "" {
+		ts, err := timetypes.GetTimestamp(until, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		sec, nsec, err := timetypes.ParseTimestamps(ts, 0)
+		if err != nil {
+			return nil, err
+		}
+		f.until = time.Unix(sec, nsec)
+	}
+	if grep != "" {
+		re, err := regexp.Compile(grep)
+		if err != nil {
+			return nil, err
+		}
+		f.grep = re
+	}
+	return f, nil
+}
+
+// empty reports whether f keeps every line.
+func (f *logsFilter) empty() bool {
+	return f.until.IsZero() && f.grep == nil
+}
+
+// needsTimestamps reports whether the lines must be read with their
+// timestamp to be filtered.
+func (f *logsFilter) needsTimestamps() bool {
+	return !f.until.IsZero()
+}
+
+// match reports whether a line of logs, split from its timestamp, is kept.
+// It returns errLogsUntil for the first line logged after f.until.
+func (f *logsFilter) match(ts time.Time, msg []byte) (bool, error) {
+	if !f.until.IsZero() && ts.After(f.until) {
+		return false, errLogsUntil
+	}
+	if f.grep != nil && !f.grep.Match(msg) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// splitLogTimestamp splits a line of logs read with timestamps in its
+// timestamp and message. Lines without valid timestamp are returned as
+// they are.
+func splitLogTimestamp(line []byte) (time.Time, []byte) {
+	if i := bytes.IndexByte(line, ' '); i > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, string(line[:i])); err == nil {
+			return ts, line[i+1:]
+		}
+	}
+	return time.Time{}, line
+}
+
+// logLineWriter splits logs in lines, each passed to a function. Lines
+// keep their trailing newline.
+type logLineWriter struct {
+	line func([]byte) error
+	buf  []byte
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := w.buf[:i+1]
+		w.buf = w.buf[i+1:]
+		if err := w.line(line); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// Close passes on the last line if it doesn't end with a newline.
+func (w *logLineWriter) Close() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := append(w.buf, '\n')
+	w.buf = nil
+	return w.line(line)
+}
+
+// filterLogs returns a logLineWriter writing the lines kept by f to out.
+// Lines are read with timestamps if hasTimestamps is set, which are only
+// written if keepTimestamps is set too.
+func filterLogs(out io.Writer, f *logsFilter, hasTimestamps, keepTimestamps bool) *logLineWriter {
+	return &logLineWriter{line: func(line []byte) error {
+		var ts time.Time
+		msg := line
+		if hasTimestamps {
+			ts, msg = splitLogTimestamp(line)
+		}
+		keep, err := f.match(ts, msg)
+		if err != nil || !keep {
+			return err
+		}
+		if !keepTimestamps {
+			line = msg
+		}
+		_, err = out.Write(line)
+		return err
+	}}
+}
+
+// logFile is a file logs are exported to, created on the first write so
+// that streams without output leave no file.
+type logFile struct {
+	path string
+	gzip bool
+
+	f  *os.File
+	gz *gzip.Writer
+}
+
+func (lf *logFile) Write(p []byte) (int, error) {
+	if lf.f == nil {
+		f, err := os.OpenFile(lf.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return 0, err
+		}
+		lf.f = f
+		if lf.gzip {
+			lf.gz = gzip.NewWriter(f)
+		}
+	}
+	if lf.gz != nil {
+		return lf.gz.Write(p)
+	}
+	return lf.f.Write(p)
+}
+
+// Close flushes the compressed data and closes the file.
+func (lf *logFile) Close() error {
+	if lf.f == nil {
+		return nil
+	}
+	var err error
+	if lf.gz != nil {
+		err = lf.gz.Close()
+	}
+	if cerr := lf.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
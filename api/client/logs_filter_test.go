@@ -0,0 +1,79 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilterLogs(t *testing.T) {
+	f, err := newLogsFilter("2016-10-01T00:00:02Z", "^err")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.NewBuffer(nil)
+	w := filterLogs(out, f, true, false)
+	_, err = w.Write([]byte("2016-10-01T00:00:00Z error 1\n2016-10-01T00:00:01Z info\n2016-10-01T00:00:02Z err"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte("or 2\n2016-10-01T00:00:03Z error 3\n")); err != errLogsUntil {
+		t.Fatalf("Expected errLogsUntil after the --until time, got %v", err)
+	}
+	if out.String() != "error 1\nerror 2\n" {
+		t.Fatalf("Unexpected filtered logs %q", out.String())
+	}
+
+	out.Reset()
+	w = filterLogs(out, f, true, true)
+	w.Write([]byte("2016-10-01T00:00:00Z error\n"))
+	if out.String() != "2016-10-01T00:00:00Z error\n" {
+		t.Fatalf("Expected the timestamp to be kept, got %q", out.String())
+	}
+
+	if _, err := newLogsFilter("", "("); err == nil {
+		t.Fatal("Expected an error for an invalid regular expression")
+	}
+}
+
+func TestLogFileGzip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	empty := &logFile{path: filepath.Join(dir, "web_1.stderr.gz"), gzip: true}
+	if err := empty.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(empty.path); !os.IsNotExist(err) {
+		t.Fatalf("Expected no file for empty logs, got %v", err)
+	}
+
+	lf := &logFile{path: filepath.Join(dir, "web_1.stdout.gz"), gzip: true}
+	lf.Write([]byte("line 1\n"))
+	lf.Write([]byte("line 2\n"))
+	if err := lf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(lf.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "line 1\nline 2\n" {
+		t.Fatalf("Unexpected logs %q", b)
+	}
+}
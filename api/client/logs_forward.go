@@ -0,0 +1,141 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperhq/hyper-api/types"
+	daemonlogger "github.com/hyperhq/hypercli/daemon/logger"
+	_ "github.com/hyperhq/hypercli/daemon/logger/fluentd"
+)
+
+// logForwarder ships the logs of containers through a logging driver, for
+// `hyper logs --forward`.
+type logForwarder struct {
+	driver string
+	// config holds the options of the driver, as given with --log-opt
+	// to the docker daemon.
+	config map[string]string
+}
+
+// newLogForwarder parses the destination of --forward:
+//
+//	fluentd[+tcp]://host[:port]
+//	syslog[+udp|+tcp|+tcp+tls]://host[:port], syslog+unix:///path
+//	gelf://host:port
+//
+// The query parameters are passed on to the driver as options, e.g.
+// fluentd://host?tag=hyper.{{.Name}}.
+func newLogForwarder(rawurl string) (*logForwarder, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	f := &logForwarder{config: make(map[string]string)}
+	scheme := strings.SplitN(u.Scheme, "+", 2)
+	proto := "udp"
+	if len(scheme) == 2 {
+		proto = scheme[1]
+	}
+	switch scheme[0] {
+	case "fluentd":
+		if len(scheme) == 2 && proto != "tcp" {
+			return nil, fmt.Errorf("invalid log destination %s: fluentd only supports tcp", rawurl)
+		}
+		f.driver = "fluentd"
+		f.config["fluentd-address"] = u.Host
+		f.config["tag"] = "hyper.{{.Name}}"
+	case "syslog":
+		f.driver = "syslog"
+		f.config["syslog-address"] = proto + "://" + u.Host + u.Path
+		f.config["tag"] = "{{.Name}}"
+	case "gelf":
+		if proto != "udp" {
+			return nil, fmt.Errorf("invalid log destination %s: gelf only supports udp", rawurl)
+		}
+		f.driver = "gelf"
+		f.config["gelf-address"] = "udp://" + u.Host
+		f.config["tag"] = "{{.Name}}"
+	default:
+		return nil, fmt.Errorf("invalid log destination %s: the scheme must be fluentd, syslog or gelf", rawurl)
+	}
+	for k, v := range u.Query() {
+		f.config[k] = v[len(v)-1]
+	}
+	if _, err := daemonlogger.GetLogDriver(f.driver); err != nil {
+		return nil, fmt.Errorf("forwarding logs to %s is not supported on this platform", f.driver)
+	}
+	if err := daemonlogger.ValidateLogOpts(f.driver, f.config); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// create returns a logger forwarding the logs of a container, tagged with
+// its name, image and labels.
+func (f *logForwarder) create(info types.ContainerJSON) (daemonlogger.Logger, error) {
+	creator, err := daemonlogger.GetLogDriver(f.driver)
+	if err != nil {
+		return nil, err
+	}
+	config := make(map[string]string, len(f.config)+1)
+	for k, v := range f.config {
+		config[k] = v
+	}
+	if _, ok := config["labels"]; !ok {
+		// attach all the labels of the container to its logs
+		keys := make([]string, 0, len(info.Config.Labels))
+		for k := range info.Config.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		config["labels"] = strings.Join(keys, ",")
+	}
+	created, _ := time.Parse(time.RFC3339Nano, info.Created)
+	name := info.Name
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	return creator(daemonlogger.Context{
+		Config:              config,
+		ContainerID:         info.ID,
+		ContainerName:       name,
+		ContainerEntrypoint: info.Path,
+		ContainerArgs:       info.Args,
+		ContainerImageID:    info.Image,
+		ContainerImageName:  info.Config.Image,
+		ContainerCreated:    created,
+		ContainerEnv:        info.Config.Env,
+		ContainerLabels:     info.Config.Labels,
+	})
+}
+
+// forwardLines returns a logLineWriter sending the lines kept by filter to
+// dl. The lines are read with timestamps, which are sent as the time of
+// the messages. Errors of dl are written to errOut without stopping.
+func forwardLines(dl daemonlogger.Logger, containerID, source string, filter *logsFilter, errOut io.Writer) *logLineWriter {
+	return &logLineWriter{line: func(line []byte) error {
+		ts, msg := splitLogTimestamp(line)
+		keep, err := filter.match(ts, msg)
+		if err != nil || !keep {
+			return err
+		}
+		if ts.IsZero() {
+			ts = time.Now().UTC()
+		}
+		msg = msg[:len(msg)-1]
+		if err := dl.Log(&daemonlogger.Message{
+			ContainerID: containerID,
+			Line:        append([]byte(nil), msg...),
+			Source:      source,
+			Timestamp:   ts,
+		}); err != nil {
+			fmt.Fprintf(errOut, "Error forwarding logs: %v\n", err)
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,8 @@
+package client
+
+import (
+	// Importing packages here only to make sure their init gets called and
+	// therefore they register themselves to the logdriver factory.
+	_ "github.com/hyperhq/hypercli/daemon/logger/gelf"
+	_ "github.com/hyperhq/hypercli/daemon/logger/syslog"
+)
@@ -0,0 +1,25 @@
+package client
+
+import "testing"
+
+func TestNewLogForwarderLinux(t *testing.T) {
+	cases := []struct {
+		url     string
+		driver  string
+		address string
+		key     string
+	}{
+		{"syslog://logs.example.com:514", "syslog", "udp://logs.example.com:514", "syslog-address"},
+		{"syslog+tcp://logs.example.com:514", "syslog", "tcp://logs.example.com:514", "syslog-address"},
+		{"gelf://graylog:12201", "gelf", "udp://graylog:12201", "gelf-address"},
+	}
+	for _, c := range cases {
+		f, err := newLogForwarder(c.url)
+		if err != nil {
+			t.Fatalf("%s: %v", c.url, err)
+		}
+		if f.driver != c.driver || f.config[c.key] != c.address {
+			t.Fatalf("%s: unexpected forwarder %+v", c.url, f)
+		}
+	}
+}
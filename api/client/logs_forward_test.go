@@ -0,0 +1,78 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	daemonlogger "github.com/hyperhq/hypercli/daemon/logger"
+)
+
+func TestNewLogForwarder(t *testing.T) {
+	cases := []struct {
+		url     string
+		driver  string
+		address string
+		key     string
+	}{
+		{"fluentd://localhost:24224", "fluentd", "localhost:24224", "fluentd-address"},
+		{"fluentd+tcp://localhost:24224", "fluentd", "localhost:24224", "fluentd-address"},
+	}
+	for _, c := range cases {
+		f, err := newLogForwarder(c.url)
+		if err != nil {
+			t.Fatalf("%s: %v", c.url, err)
+		}
+		if f.driver != c.driver || f.config[c.key] != c.address {
+			t.Fatalf("%s: unexpected forwarder %+v", c.url, f)
+		}
+	}
+
+	f, err := newLogForwarder("fluentd://localhost?tag=app.{{.Name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.config["tag"] != "app.{{.Name}}" {
+		t.Fatalf("Expected the tag to be overridden, got %q", f.config["tag"])
+	}
+
+	for _, url := range []string{"http://localhost", "fluentd+udp://localhost", "gelf+tcp://graylog:12201", "fluentd://localhost?unknown=1"} {
+		if _, err := newLogForwarder(url); err == nil {
+			t.Fatalf("Expected an error for %s", url)
+		}
+	}
+}
+
+// messageLogger records the messages logged through it.
+type messageLogger struct {
+	messages []*daemonlogger.Message
+}
+
+func (l *messageLogger) Log(m *daemonlogger.Message) error {
+	l.messages = append(l.messages, m)
+	return nil
+}
+func (l *messageLogger) Name() string { return "test" }
+func (l *messageLogger) Close() error { return nil }
+
+func TestForwardLines(t *testing.T) {
+	dl := &messageLogger{}
+	f, err := newLogsFilter("", "GET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := forwardLines(dl, "abc", "stderr", f, bytes.NewBuffer(nil))
+	w.Write([]byte("2016-10-01T00:00:01Z GET /\n2016-10-01T00:00:02Z POST /\n2016-10-01T00:00:03Z GET /health"))
+	w.Close()
+
+	if len(dl.messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(dl.messages))
+	}
+	m := dl.messages[1]
+	if string(m.Line) != "GET /health" || m.ContainerID != "abc" || m.Source != "stderr" {
+		t.Fatalf("Unexpected message %+v", m)
+	}
+	if !m.Timestamp.Equal(time.Date(2016, 10, 1, 0, 0, 3, 0, time.UTC)) {
+		t.Fatalf("Expected the timestamp of the line, got %v", m.Timestamp)
+	}
+}
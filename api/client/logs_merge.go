@@ -0,0 +1,74 @@
+package client
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperhq/libcompose/logger"
+)
+
+// logLine is a line of the logs of a container.
+type logLine struct {
+	// time is the timestamp of the line, zero if it has none.
+	time time.Time
+	// received is when the line was read.
+	received time.Time
+	logger   logger.Logger
+	stderr   bool
+	text     []byte
+}
+
+// logLines sorts lines by timestamp, keeping the order of lines logged at
+// the same time.
+type logLines []logLine
+
+func (l logLines) Len() int           { return len(l) }
+func (l logLines) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l logLines) Less(i, j int) bool { return l[i].time.Before(l[j].time) }
+
+// logMerger merges the log lines of several containers in timestamp order.
+// Lines are held until flushed, so that the lines of other containers
+// logged at the same time can be sorted in.
+type logMerger struct {
+	mu    sync.Mutex
+	lines logLines
+}
+
+// add queues a line of logs logged at ts.
+func (m *logMerger) add(lg logger.Logger, stderr bool, ts time.Time, text []byte, received time.Time) {
+	line := logLine{
+		time:     ts,
+		received: received,
+		logger:   lg,
+		stderr:   stderr,
+		text:     append([]byte(nil), text...),
+	}
+	m.mu.Lock()
+	m.lines = append(m.lines, line)
+	m.mu.Unlock()
+}
+
+// flush writes the lines received before a time, in timestamp order.
+func (m *logMerger) flush(before time.Time) {
+	m.mu.Lock()
+	var ready, pending logLines
+	for _, line := range m.lines {
+		if line.received.Before(before) {
+			ready = append(ready, line)
+		} else {
+			pending = append(pending, line)
+		}
+	}
+	m.lines = pending
+	m.mu.Unlock()
+
+	sort.Stable(ready)
+	for _, line := range ready {
+		if line.stderr {
+			line.logger.Err(line.text)
+		} else {
+			line.logger.Out(line.text)
+		}
+	}
+}
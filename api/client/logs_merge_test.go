@@ -0,0 +1,53 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// bufferLogger logs lines prefixed with its name to a shared buffer.
+type bufferLogger struct {
+	name string
+	out  *bytes.Buffer
+}
+
+func (l *bufferLogger) Out(b []byte) { l.out.WriteString(l.name + " | " + string(b)) }
+func (l *bufferLogger) Err(b []byte) { l.out.WriteString(l.name + " ! " + string(b)) }
+
+func TestLogMerger(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	web1 := &bufferLogger{"web_1", out}
+	web2 := &bufferLogger{"web_2", out}
+	m := &logMerger{}
+	l := &mergedLogs{filter: &logsFilter{}, merger: m}
+	start := time.Now()
+
+	w1 := l.mergeLines(web1, false)
+	w2 := l.mergeLines(web2, true)
+	w1.Write([]byte("2016-10-01T00:00:01.5Z second\n2016-10-01T00:00:03Z fou"))
+	w2.Write([]byte("2016-10-01T00:00:01Z first\n2016-10-01T00:00:02Z third\n"))
+	w1.Write([]byte("rth\n"))
+	w1.Write([]byte("no timestamp"))
+	w1.Close()
+
+	m.flush(start)
+	if out.Len() != 0 {
+		t.Fatalf("Expected lines received after the flush time to be held, got %q", out.String())
+	}
+	m.flush(time.Now())
+	// lines without timestamp come first
+	expected := "web_1 | no timestamp\nweb_2 ! first\nweb_1 | second\nweb_2 ! third\nweb_1 | fourth\n"
+	if out.String() != expected {
+		t.Fatalf("Unexpected merged logs %q", out.String())
+	}
+
+	out.Reset()
+	l.options.Timestamps = true
+	w1 = l.mergeLines(web1, false)
+	w1.Write([]byte("2016-10-01T00:00:01Z kept\n"))
+	m.flush(time.Now())
+	if out.String() != "web_1 | 2016-10-01T00:00:01Z kept\n" {
+		t.Fatalf("Expected the timestamp to be kept, got %q", out.String())
+	}
+}
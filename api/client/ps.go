@@ -1,6 +1,9 @@
 package client
 
 import (
+	"sort"
+
+	"github.com/hyperhq/hyper-api/client"
 	"github.com/hyperhq/hyper-api/types"
 	"github.com/hyperhq/hyper-api/types/filters"
 	"github.com/hyperhq/hypercli/api/client/formatter"
@@ -30,6 +33,7 @@ func (cli *DockerCli) CmdPs(args ...string) error {
 		last     = cmd.Int([]string{"n"}, -1, "Show n last created containers (includes all states)")
 		format   = cmd.String([]string{"-format"}, "", "Pretty-print containers using a Go template")
 		flFilter = opts.NewListOpts(nil)
+		flRegion = addRegionFlags(cmd)
 	)
 	cmd.Require(flag.Exact, 0)
 
@@ -57,10 +61,20 @@ func (cli *DockerCli) CmdPs(args ...string) error {
 		Filter: psFilterArgs,
 	}
 
-	containers, err := cli.client.ContainerList(context.Background(), options)
+	regions, err := flRegion.list()
+	if err != nil {
+		return err
+	}
+	lists := make([][]types.Container, regionCount(regions))
+	err = cli.forEachRegion(regions, func(i int, region string, c client.APIClient) error {
+		containers, err := c.ContainerList(context.Background(), options)
+		lists[i] = containers
+		return err
+	})
 	if err != nil {
 		return err
 	}
+	containers, containerRegions := mergeContainerLists(regions, lists, *last)
 
 	f := *format
 	if len(f) == 0 {
@@ -80,9 +94,45 @@ func (cli *DockerCli) CmdPs(args ...string) error {
 		},
 		Size:       *size,
 		Containers: containers,
+		Regions:    containerRegions,
 	}
 
 	psCtx.Write()
 
 	return nil
 }
+
+// byCreated sorts the containers of several regions, newest first.
+type byCreated struct {
+	containers []types.Container
+	regions    []string
+}
+
+func (s byCreated) Len() int           { return len(s.containers) }
+func (s byCreated) Less(i, j int) bool { return s.containers[i].Created > s.containers[j].Created }
+func (s byCreated) Swap(i, j int) {
+	s.containers[i], s.containers[j] = s.containers[j], s.containers[i]
+	s.regions[i], s.regions[j] = s.regions[j], s.regions[i]
+}
+
+// mergeContainerLists merges the containers listed in several regions,
+// newest first, keeping the last n if n is positive. The region of each
+// container is returned alongside, nil if a single region was listed.
+func mergeContainerLists(regions []string, lists [][]types.Container, n int) ([]types.Container, []string) {
+	if regions == nil {
+		return lists[0], nil
+	}
+	merged := byCreated{}
+	for i, list := range lists {
+		for _, container := range list {
+			merged.containers = append(merged.containers, container)
+			merged.regions = append(merged.regions, regions[i])
+		}
+	}
+	sort.Stable(merged)
+	if n > 0 && len(merged.containers) > n {
+		merged.containers = merged.containers[:n]
+		merged.regions = merged.regions[:n]
+	}
+	return merged.containers, merged.regions
+}
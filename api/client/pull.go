@@ -3,42 +3,75 @@ package client
 import (
 	"errors"
 	"fmt"
+	"os"
 
 	"golang.org/x/net/context"
 
 	"github.com/hyperhq/hyper-api/types"
 	Cli "github.com/hyperhq/hypercli/cli"
 	"github.com/hyperhq/hypercli/pkg/jsonmessage"
-	flag "github.com/hyperhq/hypercli/pkg/mflag"
 	"github.com/hyperhq/hypercli/reference"
 	"github.com/hyperhq/hypercli/registry"
 )
 
-// CmdPull pulls an image or a repository from the registry.
+// CmdPull pulls one or more images or repositories from the registry.
 //
-// Usage: docker pull [OPTIONS] IMAGENAME[:TAG|@DIGEST]
+// Usage: docker pull [OPTIONS] IMAGENAME[:TAG|@DIGEST] [IMAGENAME[:TAG|@DIGEST]...]
 func (cli *DockerCli) CmdPull(args ...string) error {
-	cmd := Cli.Subcmd("pull", []string{"NAME[:TAG|@DIGEST]"}, Cli.DockerCommands["pull"].Description, true)
+	cmd := Cli.Subcmd("pull", []string{"NAME[:TAG|@DIGEST] [NAME[:TAG|@DIGEST]...]"}, Cli.DockerCommands["pull"].Description, true)
 	allTags := cmd.Bool([]string{}, false, "Download all tagged images in the repository")
+	parallel := cmd.Int([]string{"-parallel"}, defaultPullParallel, "Number of images pulled at a time")
+	retries := cmd.Int([]string{"-retries"}, defaultPullRetries, "Number of times a failed pull is retried")
+	fromFile := cmd.String([]string{"-from-file"}, "", "Read the images to pull from a file, one per line, or '-' for STDIN")
 	addTrustedFlags(cmd, true)
-	cmd.Require(flag.Exact, 1)
 
 	cmd.ParseFlags(args, true)
-	remote := cmd.Arg(0)
 
-	distributionRef, err := reference.ParseNamed(remote)
-	if err != nil {
+	names := cmd.Args()
+	if *fromFile != "" {
+		list, err := cli.readImageListFile(*fromFile)
+		if err != nil {
+			return err
+		}
+		names = append(names, list...)
+	}
+	if len(names) == 0 {
+		cmd.ReportError("\"pull\" requires at least one image or --from-file", true)
+		os.Exit(1)
+	}
+	if *parallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1")
+	}
+	if *retries < 0 {
+		return fmt.Errorf("--retries can't be negative")
+	}
+	if *allTags && len(names) > 1 {
+		return errors.New("--all-tags/-a can only be used with a single repository")
+	}
+
+	if err := cli.checkCloudConfig(); err != nil {
 		return err
 	}
-	if *allTags && !reference.IsNameOnly(distributionRef) {
-		return errors.New("tag can't be used with --all-tags/-a")
+
+	ctx := context.Background()
+	if len(names) == 1 {
+		return cli.pullRepository(ctx, names[0], *allTags, *retries)
 	}
+	return cli.pullImages(ctx, names, *parallel, *retries)
+}
 
-	if err = cli.checkCloudConfig(); err != nil {
+// pullRepository pulls an image, or all the tagged images of a repository,
+// retrying on failure.
+func (cli *DockerCli) pullRepository(ctx context.Context, remote string, allTags bool, retries int) error {
+	distributionRef, err := reference.ParseNamed(remote)
+	if err != nil {
 		return err
 	}
+	if allTags && !reference.IsNameOnly(distributionRef) {
+		return errors.New("tag can't be used with --all-tags/-a")
+	}
 
-	if !*allTags && reference.IsNameOnly(distributionRef) {
+	if !allTags && reference.IsNameOnly(distributionRef) {
 		distributionRef = reference.WithDefaultTag(distributionRef)
 		fmt.Fprintf(cli.out, "Using default tag: %s\n", reference.DefaultTag)
 	}
@@ -59,8 +92,6 @@ func (cli *DockerCli) CmdPull(args ...string) error {
 		return err
 	}
 
-	ctx := context.Background()
-
 	authConfig := cli.resolveAuthConfig(ctx, cli.configFile.AuthConfigs, repoInfo.Index)
 	requestPrivilege := cli.registryAuthenticationPrivilegedFunc(repoInfo.Index, "pull")
 
@@ -69,7 +100,13 @@ func (cli *DockerCli) CmdPull(args ...string) error {
 		return cli.trustedPull(ctx, repoInfo, ref, authConfig, requestPrivilege)
 	}
 
-	return cli.imagePullPrivileged(ctx, authConfig, distributionRef.String(), requestPrivilege, *allTags)
+	attempt := 0
+	return withRetry(retries, func() error {
+		if attempt++; attempt > 1 {
+			fmt.Fprintf(cli.out, "Retrying pull of %s (attempt %d of %d)\n", distributionRef.String(), attempt, retries+1)
+		}
+		return newPullError(cli.imagePullPrivileged(ctx, authConfig, distributionRef.String(), requestPrivilege, allTags))
+	})
 }
 
 func (cli *DockerCli) imagePullPrivileged(ctx context.Context, authConfig types.AuthConfig, ref string, requestPrivilege types.RequestPrivilegeFunc, all bool) error {
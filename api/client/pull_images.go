@@ -0,0 +1,273 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/hypercli/pkg/jsonmessage"
+	"github.com/hyperhq/hypercli/reference"
+	"github.com/hyperhq/hypercli/registry"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultPullParallel = 4
+	defaultPullRetries  = 3
+)
+
+// permanentPullErrors are the messages of the errors of a pull that retrying
+// can't fix.
+var permanentPullErrors = []string{
+	"not found",
+	"unauthorized",
+	"denied",
+	"manifest unknown",
+	"invalid reference",
+}
+
+// pullError is an error of a pull, retried unless it is permanent.
+type pullError struct {
+	err error
+}
+
+func newPullError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return pullError{err: err}
+}
+
+func (e pullError) Error() string {
+	return e.err.Error()
+}
+
+// temporary reports whether pulling again may succeed.
+func (e pullError) temporary() bool {
+	if jerr, ok := e.err.(*jsonmessage.JSONError); ok && jerr.Code >= 400 && jerr.Code < 500 {
+		return jerr.Code == 408 || jerr.Code == 429
+	}
+	msg := strings.ToLower(e.err.Error())
+	for _, permanent := range permanentPullErrors {
+		if strings.Contains(msg, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+// readImageListFile returns the images listed in a file for `pull
+// --from-file`, or in STDIN if path is "-".
+func (cli *DockerCli) readImageListFile(path string) ([]string, error) {
+	if path == "-" {
+		return readImageList(cli.in)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readImageList(f)
+}
+
+// readImageList reads a list of images, one per line. Blank lines and
+// lines starting with '#' are skipped.
+func readImageList(r io.Reader) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, scanner.Err()
+}
+
+// imagePull is an image pulled along with others.
+type imagePull struct {
+	ref  reference.Named
+	auth types.AuthConfig
+	// tag is the ID of the messages of the server about the image as a
+	// whole, rather than about one of its layers.
+	tag              string
+	requestPrivilege types.RequestPrivilegeFunc
+}
+
+// pullProgress combines the progress of several pulls into one stream of
+// JSON messages.
+type pullProgress struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// send passes on a message of the pull of an image. Messages about the
+// image as a whole are labeled with its name, the progress of its layers
+// is left as is since layers are identified by their ID.
+func (p *pullProgress) send(pull *imagePull, m *jsonmessage.JSONMessage) {
+	if m.Progress == nil && m.ProgressMessage == "" && (m.ID == "" || m.ID == pull.tag) {
+		m.ID = pull.ref.String()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// the display only fails if the output does, it is reported once the
+	// pulls are done
+	p.enc.Encode(m)
+}
+
+func (p *pullProgress) status(pull *imagePull, format string, args ...interface{}) {
+	p.send(pull, &jsonmessage.JSONMessage{Status: fmt.Sprintf(format, args...)})
+}
+
+// pullImages pulls several images, at most parallel at a time, each
+// retried on failure. Their progress is displayed as one, followed by a
+// summary of the images that couldn't be pulled.
+func (cli *DockerCli) pullImages(ctx context.Context, names []string, parallel, retries int) error {
+	if isTrusted() {
+		// verified pulls print their own progress, one image at a time
+		return cli.pullSummary(names, func(i int) error {
+			return cli.pullRepository(ctx, names[i], false, retries)
+		})
+	}
+
+	var privilegeMu sync.Mutex
+	pulls := make([]*imagePull, len(names))
+	for i, name := range names {
+		ref, err := reference.ParseNamed(name)
+		if err != nil {
+			return err
+		}
+		ref = reference.WithDefaultTag(ref)
+		repoInfo, err := registry.ParseRepositoryInfo(ref)
+		if err != nil {
+			return err
+		}
+		pull := &imagePull{
+			ref:  ref,
+			auth: cli.resolveAuthConfig(ctx, cli.configFile.AuthConfigs, repoInfo.Index),
+		}
+		switch x := ref.(type) {
+		case reference.Canonical:
+			pull.tag = x.Digest().String()
+		case reference.NamedTagged:
+			pull.tag = x.Tag()
+		}
+		// login prompts of parallel pulls are asked one at a time
+		requestPrivilege := cli.registryAuthenticationPrivilegedFunc(repoInfo.Index, "pull")
+		pull.requestPrivilege = func() (string, error) {
+			privilegeMu.Lock()
+			defer privilegeMu.Unlock()
+			return requestPrivilege()
+		}
+		pulls[i] = pull
+	}
+
+	pr, pw := io.Pipe()
+	progress := &pullProgress{enc: json.NewEncoder(pw)}
+	displayed := make(chan error, 1)
+	go func() {
+		err := jsonmessage.DisplayJSONMessagesStream(pr, cli.out, cli.outFd, cli.isTerminalOut, nil)
+		// unblock the pulls if the display failed
+		pr.CloseWithError(err)
+		displayed <- err
+	}()
+
+	var (
+		wg    sync.WaitGroup
+		queue = make(chan int)
+		errs  = make([]error, len(pulls))
+	)
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				pull := pulls[i]
+				attempt := 0
+				errs[i] = withRetry(retries, func() error {
+					if attempt++; attempt > 1 {
+						progress.status(pull, "Retrying (attempt %d of %d)", attempt, retries+1)
+					}
+					err := cli.pullImageProgress(ctx, pull, progress)
+					if err != nil {
+						progress.status(pull, "Error: %v", err)
+					}
+					return err
+				})
+			}
+		}()
+	}
+	for i := range pulls {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	pw.Close()
+	if err := <-displayed; err != nil {
+		return err
+	}
+
+	return cli.pullSummary(names, func(i int) error {
+		return errs[i]
+	})
+}
+
+// pullSummary calls pull for each image, and prints the images that
+// failed to be pulled.
+func (cli *DockerCli) pullSummary(names []string, pull func(i int) error) error {
+	var failed []string
+	for i, name := range names {
+		if err := pull(i); err != nil {
+			fmt.Fprintf(cli.err, "Error pulling %s: %v\n", name, err)
+			failed = append(failed, name)
+		}
+	}
+	fmt.Fprintf(cli.out, "Pulled %d of %d images\n", len(names)-len(failed), len(names))
+	if len(failed) > 0 {
+		return fmt.Errorf("Error: failed to pull images: %v", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// pullImageProgress pulls an image, sending the progress reported by the
+// server to progress.
+func (cli *DockerCli) pullImageProgress(ctx context.Context, pull *imagePull, progress *pullProgress) error {
+	encodedAuth, err := encodeAuthToBase64(pull.auth)
+	if err != nil {
+		return err
+	}
+	options := types.ImagePullOptions{
+		PrivilegeFunc: pull.requestPrivilege,
+		RegistryAuth:  encodedAuth,
+	}
+
+	responseBody, err := cli.client.ImagePull(ctx, pull.ref.String(), options)
+	if err != nil {
+		return newPullError(err)
+	}
+	defer responseBody.Close()
+
+	dec := json.NewDecoder(responseBody)
+	for {
+		var m jsonmessage.JSONMessage
+		if err := dec.Decode(&m); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return newPullError(err)
+		}
+		if m.Error != nil {
+			return newPullError(m.Error)
+		}
+		if m.ErrorMessage != "" {
+			return newPullError(errors.New(m.ErrorMessage))
+		}
+		progress.send(pull, &m)
+	}
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperhq/hypercli/pkg/jsonmessage"
+	"github.com/hyperhq/hypercli/reference"
+)
+
+func TestReadImageList(t *testing.T) {
+	list := `
+# base images
+busybox
+  nginx:1.11  
+
+hyperhq/nfs-server@sha256:4d1bd1b4e5e8a8b2c5b1eaa4d2a3cb2c1b8e4f3a0e8d7e1f2f9a0b6c5d4e3f2a
+`
+	names, err := readImageList(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"busybox",
+		"nginx:1.11",
+		"hyperhq/nfs-server@sha256:4d1bd1b4e5e8a8b2c5b1eaa4d2a3cb2c1b8e4f3a0e8d7e1f2f9a0b6c5d4e3f2a",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+}
+
+func TestPullErrorTemporary(t *testing.T) {
+	cases := []struct {
+		err       error
+		temporary bool
+	}{
+		{errors.New("net/http: TLS handshake timeout"), true},
+		{errors.New("Error: image library/nosuchimage not found"), false},
+		{errors.New("unauthorized: authentication required"), false},
+		{&jsonmessage.JSONError{Code: 404, Message: "manifest unknown"}, false},
+		{&jsonmessage.JSONError{Code: 429, Message: "too many requests"}, true},
+		{&jsonmessage.JSONError{Code: 503, Message: "service unavailable"}, true},
+	}
+	for _, c := range cases {
+		if temporary := (pullError{err: c.err}).temporary(); temporary != c.temporary {
+			t.Fatalf("%v: expected temporary %v, got %v", c.err, c.temporary, temporary)
+		}
+	}
+	if newPullError(nil) != nil {
+		t.Fatal("expected no error")
+	}
+}
+
+func TestPullProgressSend(t *testing.T) {
+	ref, err := reference.ParseNamed("nginx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pull := &imagePull{ref: reference.WithDefaultTag(ref), tag: "latest"}
+	buf := new(bytes.Buffer)
+	progress := &pullProgress{enc: json.NewEncoder(buf)}
+
+	progress.send(pull, &jsonmessage.JSONMessage{ID: "latest", Status: "Pulling from library/nginx"})
+	progress.send(pull, &jsonmessage.JSONMessage{ID: "8ad8b3f87b37", Status: "Downloading", Progress: &jsonmessage.JSONProgress{Current: 1, Total: 2}})
+	progress.status(pull, "Retrying (attempt %d of %d)", 2, 4)
+
+	var ids []string
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var m jsonmessage.JSONMessage
+		if err := dec.Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, m.ID)
+	}
+	expected := []string{"nginx:latest", "8ad8b3f87b37", "nginx:latest"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("expected the messages about the image to be labeled with its name %v, got %v", expected, ids)
+	}
+}
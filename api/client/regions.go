@@ -0,0 +1,133 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hyperhq/hyper-api/client"
+	"github.com/hyperhq/hypercli/cliconfig"
+	flag "github.com/hyperhq/hypercli/pkg/mflag"
+)
+
+// regionFlags are the flags of the commands listing resources across
+// several regions.
+type regionFlags struct {
+	all     *bool
+	regions *string
+}
+
+func addRegionFlags(cmd *flag.FlagSet) *regionFlags {
+	return &regionFlags{
+		all:     cmd.Bool([]string{"-all-regions"}, false, "List the resources of all regions"),
+		regions: cmd.String([]string{"-region"}, "", "List the resources of a comma-separated list of regions"),
+	}
+}
+
+// list returns the regions to list, or nil to only list the region of
+// the client.
+func (f *regionFlags) list() ([]string, error) {
+	return parseRegions(*f.all, *f.regions)
+}
+
+func parseRegions(all bool, list string) ([]string, error) {
+	if all {
+		if list != "" {
+			return nil, fmt.Errorf("Conflicting options: --all-regions and --region")
+		}
+		return cliconfig.HyperRegions, nil
+	}
+	if list == "" {
+		return nil, nil
+	}
+	var regions []string
+	seen := make(map[string]bool)
+	for _, region := range strings.Split(list, ",") {
+		region = strings.TrimSpace(region)
+		if region == "" {
+			return nil, fmt.Errorf("invalid region list %q", list)
+		}
+		if !seen[region] {
+			seen[region] = true
+			regions = append(regions, region)
+		}
+	}
+	return regions, nil
+}
+
+// regionCount returns the number of listings made for regions.
+func regionCount(regions []string) int {
+	if regions == nil {
+		return 1
+	}
+	return len(regions)
+}
+
+// listingRegions returns the region of each element of the listings of
+// regions, given their lengths, or nil if a single region was listed.
+func listingRegions(regions []string, lengths []int) []string {
+	if regions == nil {
+		return nil
+	}
+	var result []string
+	for i, n := range lengths {
+		for j := 0; j < n; j++ {
+			result = append(result, regions[i])
+		}
+	}
+	return result
+}
+
+// regionColumn returns the first cell of a row of a table listing several
+// regions, that is the region of the i-th listing followed by a tab, or
+// the header of the column if i is negative. Listing a single region, the
+// column is left out.
+func regionColumn(regions []string, i int) string {
+	switch {
+	case regions == nil:
+		return ""
+	case i < 0:
+		return "REGION\t"
+	default:
+		return regions[i] + "\t"
+	}
+}
+
+// forEachRegion calls fn concurrently with a client of each region, i
+// being the index of the region. Without regions, fn is called once with
+// the client of the current region. The error of the first failed region
+// is returned once all are done.
+func (cli *DockerCli) forEachRegion(regions []string, fn func(i int, region string, c client.APIClient) error) error {
+	if regions == nil {
+		return fn(0, cli.region, cli.client)
+	}
+	clients := make([]client.APIClient, len(regions))
+	for i, region := range regions {
+		if region == cli.region {
+			clients[i] = cli.client
+			continue
+		}
+		c, err := cli.regionClient(region)
+		if err != nil {
+			return err
+		}
+		clients[i] = c
+	}
+
+	errs := make([]error, len(regions))
+	var wg sync.WaitGroup
+	for i, region := range regions {
+		wg.Add(1)
+		go func(i int, region string) {
+			defer wg.Done()
+			errs[i] = fn(i, region, clients[i])
+		}(i, region)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("Error listing region %s: %v", regions[i], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/hypercli/cliconfig"
+)
+
+func TestParseRegions(t *testing.T) {
+	cases := []struct {
+		all      bool
+		list     string
+		expected []string
+		err      bool
+	}{
+		{false, "", nil, false},
+		{true, "", cliconfig.HyperRegions, false},
+		{true, "us-west-1", nil, true},
+		{false, "us-west-1", []string{"us-west-1"}, false},
+		{false, "us-west-1, eu-central-1,us-west-1", []string{"us-west-1", "eu-central-1"}, false},
+		{false, "us-west-1,", nil, true},
+	}
+	for _, c := range cases {
+		regions, err := parseRegions(c.all, c.list)
+		if (err != nil) != c.err {
+			t.Fatalf("parseRegions(%v, %q): unexpected error %v", c.all, c.list, err)
+		}
+		if !reflect.DeepEqual(regions, c.expected) {
+			t.Fatalf("parseRegions(%v, %q): expected %v, got %v", c.all, c.list, c.expected, regions)
+		}
+	}
+}
+
+func TestListingRegions(t *testing.T) {
+	if regions := listingRegions(nil, []int{2}); regions != nil {
+		t.Fatalf("expected no regions for a single region, got %v", regions)
+	}
+	regions := listingRegions([]string{"us-west-1", "eu-central-1"}, []int{1, 2})
+	expected := []string{"us-west-1", "eu-central-1", "eu-central-1"}
+	if !reflect.DeepEqual(regions, expected) {
+		t.Fatalf("expected %v, got %v", expected, regions)
+	}
+	if column := regionColumn(nil, 0); column != "" {
+		t.Fatalf("expected no region column for a single region, got %q", column)
+	}
+	if column := regionColumn([]string{"us-west-1"}, -1); column != "REGION\t" {
+		t.Fatalf("expected the region header, got %q", column)
+	}
+}
+
+func TestMergeContainerLists(t *testing.T) {
+	lists := [][]types.Container{
+		{{ID: "a", Created: 3}, {ID: "b", Created: 1}},
+		{{ID: "c", Created: 2}},
+	}
+	containers, regions := mergeContainerLists([]string{"us-west-1", "eu-central-1"}, lists, 2)
+	var ids []string
+	for _, c := range containers {
+		ids = append(ids, c.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"a", "c"}) {
+		t.Fatalf("expected the 2 newest containers, got %v", ids)
+	}
+	if !reflect.DeepEqual(regions, []string{"us-west-1", "eu-central-1"}) {
+		t.Fatalf("unexpected regions %v", regions)
+	}
+
+	containers, regions = mergeContainerLists(nil, lists[:1], -1)
+	if len(containers) != 2 || regions != nil {
+		t.Fatalf("expected the containers of a single region as is, got %v, %v", containers, regions)
+	}
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/hyperhq/hypercli/cliconfig"
+	"github.com/hyperhq/hypercli/dockerversion"
+	"github.com/hyperhq/hypercli/pkg/selfupdate"
+)
+
+const updateURL = "https://hyper-update.s3.amazonaws.com/"
+
+// updateChannels are the release channels of hyper.
+var updateChannels = map[string]bool{
+	selfupdate.StableChannel: true,
+	"beta":                   true,
+}
+
+// newUpdater returns the updater of hyper for a release channel. Builds
+// without the public key the updates are signed with can't update.
+func newUpdater(channel string) (*selfupdate.Updater, error) {
+	if channel == "" {
+		channel = selfupdate.StableChannel
+	}
+	if !updateChannels[channel] {
+		return nil, fmt.Errorf("unknown update channel %q, must be stable or beta", channel)
+	}
+	if dockerversion.UpdatePublicKey == "" {
+		return nil, errors.New("this build of hyper can't verify updates, please download new releases from https://hyper.sh")
+	}
+	key, err := selfupdate.ParsePublicKey(dockerversion.UpdatePublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid update public key: %v", err)
+	}
+	return &selfupdate.Updater{
+		CurrentVersion: dockerversion.Version,
+		ApiURL:         updateURL,
+		BinURL:         updateURL,
+		DiffURL:        updateURL,
+		Dir:            cliconfig.ConfigDir(),
+		CmdName:        "hyper",
+		Channel:        channel,
+		PublicKey:      key,
+	}, nil
+}
+
+// UpdateNotice checks for a newer release of hyper in the background, at
+// most once a day, unless HYPER_NO_UPDATE is set or noUpdateCheck is set
+// in the config file. The returned channel receives a notice if there is
+// a newer release, and is closed once the check is done.
+func UpdateNotice() <-chan string {
+	notices := make(chan string, 1)
+	configFile, err := cliconfig.Load(cliconfig.ConfigDir())
+	if os.Getenv("HYPER_NO_UPDATE") != "" || err != nil || configFile.NoUpdateCheck {
+		close(notices)
+		return notices
+	}
+	u, err := newUpdater(configFile.UpdateChannel)
+	if err != nil {
+		close(notices)
+		return notices
+	}
+	go func() {
+		defer close(notices)
+		if u.WantUpdate() {
+			notices <- fmt.Sprintf("hyper %s is available, run 'hyper update' to install it.", u.Info.Version)
+		}
+	}()
+	return notices
+}
+
+// selfUpdate checks for a newer release of hyper on a channel, and
+// installs it unless check is set.
+func (cli *DockerCli) selfUpdate(channel string, check bool) error {
+	if channel == "" {
+		channel = cli.configFile.UpdateChannel
+	}
+	u, err := newUpdater(channel)
+	if err != nil {
+		return err
+	}
+	available, err := u.Check()
+	if err != nil {
+		return fmt.Errorf("Error checking for updates: %v", err)
+	}
+	if !available {
+		fmt.Fprintf(cli.out, "hyper %s is up to date\n", dockerversion.Version)
+		return nil
+	}
+	fmt.Fprintf(cli.out, "hyper %s is available (current version %s)\n", u.Info.Version, dockerversion.Version)
+	if check {
+		return nil
+	}
+	fmt.Fprintln(cli.out, "Downloading...")
+	if err := u.Apply(); err != nil {
+		return fmt.Errorf("Error updating hyper: %v", err)
+	}
+	fmt.Fprintf(cli.out, "Updated hyper to %s\n", u.Info.Version)
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"text/tabwriter"
 
+	"github.com/hyperhq/hyper-api/client"
 	"github.com/hyperhq/hyper-api/types"
 	"github.com/hyperhq/hyper-api/types/filters"
 	"github.com/hyperhq/hyper-api/types/strslice"
@@ -192,6 +193,7 @@ func (cli *DockerCli) CmdServiceLs(args ...string) error {
 
 	flFilter := ropts.NewListOpts(nil)
 	cmd.Var(&flFilter, []string{"f", "-filter"}, "Filter output based on conditions provided")
+	flRegion := addRegionFlags(cmd)
 
 	cmd.Require(flag.Exact, 0)
 	err := cmd.ParseFlags(args, true)
@@ -212,14 +214,25 @@ func (cli *DockerCli) CmdServiceLs(args ...string) error {
 		Filters: serviceFilterArgs,
 	}
 
-	services, err := cli.client.ServiceList(context.Background(), options)
+	regions, err := flRegion.list()
+	if err != nil {
+		return err
+	}
+	lists := make([][]types.Service, regionCount(regions))
+	err = cli.forEachRegion(regions, func(i int, region string, c client.APIClient) error {
+		services, err := c.ServiceList(context.Background(), options)
+		lists[i] = services
+		return err
+	})
 	if err != nil {
 		return err
 	}
 	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
-	fmt.Fprintf(w, "Name\tFIP\tContainers\tStatus\tMessage\n")
-	for _, service := range services {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", service.Name, service.FIP, showContainersInList(service.Containers), service.Status, service.Message)
+	fmt.Fprintf(w, "%sName\tFIP\tContainers\tStatus\tMessage\n", regionColumn(regions, -1))
+	for i, services := range lists {
+		for _, service := range services {
+			fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\n", regionColumn(regions, i), service.Name, service.FIP, showContainersInList(service.Containers), service.Status, service.Message)
+		}
 	}
 
 	w.Flush()
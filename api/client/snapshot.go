@@ -2,12 +2,12 @@ package client
 
 import (
 	"fmt"
-	"text/tabwriter"
 
 	"golang.org/x/net/context"
 
 	"github.com/hyperhq/hyper-api/types"
 	"github.com/hyperhq/hyper-api/types/filters"
+	"github.com/hyperhq/hypercli/api/client/formatter"
 	Cli "github.com/hyperhq/hypercli/cli"
 	"github.com/hyperhq/hypercli/opts"
 	flag "github.com/hyperhq/hypercli/pkg/mflag"
@@ -22,7 +22,9 @@ func (cli *DockerCli) CmdSnapshot(args ...string) error {
 		{"create", "Create a snapshot"},
 		{"inspect", "Return low-level information on a snapshot"},
 		{"ls", "List snapshots"},
+		{"prune", "Remove the snapshots of a volume outside of a retention policy"},
 		{"rm", "Remove a snapshot"},
+		{"schedule", "Take snapshots of a volume periodically"},
 	}
 
 	for _, cmd := range commands {
@@ -45,6 +47,7 @@ func (cli *DockerCli) CmdSnapshotLs(args ...string) error {
 	cmd := Cli.Subcmd("snapshot ls", nil, "List snapshots", true)
 
 	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Only display snapshot names")
+	format := cmd.String([]string{"-format"}, "", "Pretty-print snapshots using a Go template")
 	flFilter := opts.NewListOpts(nil)
 	cmd.Var(&flFilter, []string{"f", "-filter"}, "Provide filter values (i.e. 'dangling=true')")
 
@@ -65,23 +68,31 @@ func (cli *DockerCli) CmdSnapshotLs(args ...string) error {
 		return err
 	}
 
-	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
 	if !*quiet {
 		for _, warn := range snapshots.Warnings {
 			fmt.Fprintln(cli.err, warn)
 		}
-		fmt.Fprintf(w, "Snapshot Name \tVolume\tSize")
-		fmt.Fprintf(w, "\n")
 	}
 
-	for _, vol := range snapshots.Snapshots {
-		if *quiet {
-			fmt.Fprintln(w, vol.Name)
-			continue
+	f := *format
+	if len(f) == 0 {
+		if len(cli.SnapshotsFormat()) > 0 && !*quiet {
+			f = cli.SnapshotsFormat()
+		} else {
+			f = "table"
 		}
-		fmt.Fprintf(w, "%s\t%s\t%d\n", vol.Name, vol.Volume, vol.Size)
 	}
-	w.Flush()
+
+	snapCtx := formatter.SnapshotContext{
+		Context: formatter.Context{
+			Output: cli.out,
+			Format: f,
+			Quiet:  *quiet,
+		},
+		Snapshots: snapshots.Snapshots,
+	}
+
+	snapCtx.Write()
 	return nil
 }
 
@@ -0,0 +1,449 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/hyper-api/types/container"
+	"github.com/hyperhq/hyper-api/types/filters"
+	"github.com/hyperhq/hyper-api/types/network"
+	"github.com/hyperhq/hyper-api/types/strslice"
+	Cli "github.com/hyperhq/hypercli/cli"
+	flag "github.com/hyperhq/hypercli/pkg/mflag"
+	"golang.org/x/net/context"
+)
+
+const (
+	// scheduledSnapshotInfix separates the volume name from the creation
+	// time in the name of the snapshots taken by a schedule.
+	scheduledSnapshotInfix      = "-auto-"
+	scheduledSnapshotTimeLayout = "20060102150405"
+
+	defaultSnapshotScheduleImage = "hyperhq/hypercli"
+
+	snapshotVolumeLabel    = "sh_hyper_snapshot_volume"
+	snapshotKeepLabel      = "sh_hyper_snapshot_keep"
+	snapshotKeepDailyLabel = "sh_hyper_snapshot_keep_daily"
+	snapshotKeepLastLabel  = "sh_hyper_snapshot_keep_last"
+)
+
+// snapshotRetention is the policy applied by `hyper snapshot prune`. A
+// snapshot is kept as soon as one of the rules selects it.
+type snapshotRetention struct {
+	// Within keeps every snapshot younger than this duration.
+	Within time.Duration
+	// Daily keeps the newest snapshot of each of the last Daily days
+	// having snapshots.
+	Daily int
+	// Last keeps the Last newest snapshots.
+	Last int
+}
+
+func (r snapshotRetention) empty() bool {
+	return r.Within == 0 && r.Daily == 0 && r.Last == 0
+}
+
+// labels returns the policy as cron labels, so that prune can find it.
+func (r snapshotRetention) labels() map[string]string {
+	labels := make(map[string]string)
+	if r.Within != 0 {
+		labels[snapshotKeepLabel] = r.Within.String()
+	}
+	if r.Daily != 0 {
+		labels[snapshotKeepDailyLabel] = strconv.Itoa(r.Daily)
+	}
+	if r.Last != 0 {
+		labels[snapshotKeepLastLabel] = strconv.Itoa(r.Last)
+	}
+	return labels
+}
+
+// args returns the `hyper snapshot prune` flags of the policy.
+func (r snapshotRetention) args() []string {
+	var args []string
+	if r.Within != 0 {
+		args = append(args, "--keep", r.Within.String())
+	}
+	if r.Daily != 0 {
+		args = append(args, "--keep-daily", strconv.Itoa(r.Daily))
+	}
+	if r.Last != 0 {
+		args = append(args, "--keep-last", strconv.Itoa(r.Last))
+	}
+	return args
+}
+
+func parseSnapshotRetention(keep string, daily, last int) (snapshotRetention, error) {
+	var r snapshotRetention
+	if keep != "" {
+		d, err := parseLongDuration(keep)
+		if err != nil {
+			return r, fmt.Errorf("invalid --keep: %v", err)
+		}
+		r.Within = d
+	}
+	if daily < 0 || last < 0 {
+		return r, fmt.Errorf("--keep-daily and --keep-last must not be negative")
+	}
+	r.Daily = daily
+	r.Last = last
+	return r, nil
+}
+
+func snapshotRetentionFromLabels(labels map[string]string) (snapshotRetention, error) {
+	var daily, last int
+	var err error
+	if v := labels[snapshotKeepDailyLabel]; v != "" {
+		if daily, err = strconv.Atoi(v); err != nil {
+			return snapshotRetention{}, err
+		}
+	}
+	if v := labels[snapshotKeepLastLabel]; v != "" {
+		if last, err = strconv.Atoi(v); err != nil {
+			return snapshotRetention{}, err
+		}
+	}
+	return parseSnapshotRetention(labels[snapshotKeepLabel], daily, last)
+}
+
+// parseLongDuration parses a duration, also accepting days ("7d") and
+// weeks ("2w") which time.ParseDuration does not support.
+func parseLongDuration(s string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	var d time.Duration
+	if unit != 0 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", s)
+		}
+		d = time.Duration(n) * unit
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, err
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid duration %s", s)
+	}
+	return d, nil
+}
+
+// intervalToCronSchedule converts an interval to a cron expression
+// ("minute hour day-of-month month day-of-week"). Only intervals that
+// divide an hour, a day or are a whole number of days are supported.
+func intervalToCronSchedule(d time.Duration) (string, error) {
+	day := 24 * time.Hour
+	switch {
+	case d < time.Minute || d%time.Minute != 0:
+	case d < time.Hour:
+		if m := int(d / time.Minute); 60%m == 0 {
+			return fmt.Sprintf("*/%d * * * *", m), nil
+		}
+	case d < day:
+		if d%time.Hour != 0 {
+			break
+		}
+		if h := int(d / time.Hour); h == 1 {
+			return "0 * * * *", nil
+		} else if 24%h == 0 {
+			return fmt.Sprintf("0 */%d * * *", h), nil
+		}
+	case d%day == 0:
+		switch n := int(d / day); n {
+		case 1:
+			return "0 0 * * *", nil
+		case 7:
+			return "0 0 * * 0", nil
+		default:
+			return fmt.Sprintf("0 0 */%d * *", n), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported interval %s, use a divisor of 1h or 24h, or a number of days", d)
+}
+
+// snapshotTime returns the creation time of a snapshot, falling back on
+// the time encoded in the name of scheduled snapshots.
+func snapshotTime(s *types.Snapshot) (time.Time, bool) {
+	if s.Created != 0 {
+		return time.Unix(s.Created, 0), true
+	}
+	idx := strings.LastIndex(s.Name, scheduledSnapshotInfix)
+	if idx == -1 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(scheduledSnapshotTimeLayout, s.Name[idx+len(scheduledSnapshotInfix):])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+type datedSnapshot struct {
+	s *types.Snapshot
+	t time.Time
+}
+
+type datedSnapshots []datedSnapshot
+
+func (l datedSnapshots) Len() int           { return len(l) }
+func (l datedSnapshots) Less(i, j int) bool { return l[i].t.Before(l[j].t) }
+func (l datedSnapshots) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// pruneSnapshots returns the snapshots that the retention policy does not
+// keep. Snapshots with an unknown creation time are always kept.
+func pruneSnapshots(snapshots []*types.Snapshot, r snapshotRetention, now time.Time) []*types.Snapshot {
+	var list datedSnapshots
+	for _, s := range snapshots {
+		if t, ok := snapshotTime(s); ok {
+			list = append(list, datedSnapshot{s, t})
+		}
+	}
+	// newest first
+	sort.Sort(sort.Reverse(list))
+
+	keep := make(map[*types.Snapshot]bool)
+	days := make(map[string]bool)
+	for i, d := range list {
+		if i < r.Last {
+			keep[d.s] = true
+		}
+		if r.Within != 0 && now.Sub(d.t) <= r.Within {
+			keep[d.s] = true
+		}
+		if day := d.t.UTC().Format("2006-01-02"); !days[day] && len(days) < r.Daily {
+			days[day] = true
+			keep[d.s] = true
+		}
+	}
+
+	var prune []*types.Snapshot
+	for _, d := range list {
+		if !keep[d.s] {
+			prune = append(prune, d.s)
+		}
+	}
+	return prune
+}
+
+func snapshotScheduleName(volume string) string {
+	return "snapshot-" + volume
+}
+
+// findSnapshotSchedule returns the cron taking the snapshots of volume,
+// the one named name if not empty.
+func (cli *DockerCli) findSnapshotSchedule(ctx context.Context, volume, name string) (types.Cron, error) {
+	if name != "" {
+		cron, err := cli.client.CronInspect(ctx, name)
+		if err != nil {
+			return types.Cron{}, err
+		}
+		if cron.Config == nil || cron.Config.Labels[snapshotVolumeLabel] != volume {
+			return types.Cron{}, fmt.Errorf("cron %s is not a snapshot schedule of %s", name, volume)
+		}
+		return cron, nil
+	}
+	crons, err := cli.client.CronList(ctx, types.CronListOptions{Filters: filters.NewArgs()})
+	if err != nil {
+		return types.Cron{}, err
+	}
+	schedules := snapshotSchedules(crons, volume)
+	switch len(schedules) {
+	case 0:
+		return types.Cron{}, fmt.Errorf("no snapshot schedule found for %s", volume)
+	case 1:
+		return schedules[0], nil
+	default:
+		var names []string
+		for _, c := range schedules {
+			names = append(names, c.Name)
+		}
+		return types.Cron{}, fmt.Errorf("several snapshot schedules found for %s (%s), please specify one with --name", volume, strings.Join(names, ", "))
+	}
+}
+
+// snapshotSchedules returns the crons taking the snapshots of volume.
+func snapshotSchedules(crons []types.Cron, volume string) []types.Cron {
+	var schedules []types.Cron
+	for _, c := range crons {
+		if c.Config != nil && c.Config.Labels[snapshotVolumeLabel] == volume {
+			schedules = append(schedules, c)
+		}
+	}
+	return schedules
+}
+
+// snapshotScheduleJob returns the container of the cron job taking the
+// snapshots of volume. The hyper client of the job reads its credentials
+// from HYPER_ACCESS and HYPER_SECRET, so the keys are visible to whoever
+// can inspect the cron: a dedicated key should be used.
+func snapshotScheduleJob(volume, image, region, accessKey, secretKey string, retention snapshotRetention) *container.Config {
+	script := fmt.Sprintf("hyper snapshot create --force -v %s --name %s%s$(date -u +%s)", volume, volume, scheduledSnapshotInfix, "%Y%m%d%H%M%S")
+	if !retention.empty() {
+		script += " && hyper snapshot prune " + strings.Join(append(retention.args(), volume), " ")
+	}
+
+	labels := retention.labels()
+	labels[snapshotVolumeLabel] = volume
+	labels["sh_hyper_instancetype"] = "s1"
+	return &container.Config{
+		Image:      image,
+		Entrypoint: strslice.StrSlice{"sh", "-c"},
+		Cmd:        strslice.StrSlice{script},
+		Labels:     labels,
+		Env: []string{
+			"HYPER_DEFAULT_REGION=" + region,
+			"HYPER_ACCESS=" + accessKey,
+			"HYPER_SECRET=" + secretKey,
+		},
+	}
+}
+
+// CmdSnapshotSchedule creates a cron job taking snapshots of a volume
+// periodically and pruning them according to a retention policy.
+//
+// Usage: hyper snapshot schedule [OPTIONS] VOLUME
+func (cli *DockerCli) CmdSnapshotSchedule(args ...string) error {
+	cmd := Cli.Subcmd("snapshot schedule", []string{"VOLUME"}, "Take snapshots of a volume periodically, using a cron job", true)
+	flEvery := cmd.String([]string{"-every"}, "", "Interval between snapshots (e.g. 30m, 6h, 1d)")
+	flKeep := cmd.String([]string{"-keep"}, "", "Keep every snapshot younger than this duration (e.g. 48h, 7d, 2w)")
+	flKeepDaily := cmd.Int([]string{"-keep-daily"}, 0, "Keep the last snapshot of each of the last N days")
+	flKeepLast := cmd.Int([]string{"-keep-last"}, 0, "Keep the N most recent snapshots")
+	flName := cmd.String([]string{"-name"}, "", "Cron name, snapshot-VOLUME by default")
+	flImage := cmd.String([]string{"-image"}, defaultSnapshotScheduleImage, "Image with the hyper client used by the cron job")
+	flAccessKey := cmd.String([]string{"-access-key"}, "", "Access key the cron job runs with, preferably a dedicated one as it is visible in the environment of the job")
+	flSecretKey := cmd.String([]string{"-secret-key"}, "", "Secret key the cron job runs with")
+	flMailTo := cmd.String([]string{"-mailto"}, "", "Mail to when a snapshot fails")
+	cmd.Require(flag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	volume := cmd.Arg(0)
+	if *flEvery == "" {
+		return fmt.Errorf("--every must be specified")
+	}
+	every, err := parseLongDuration(*flEvery)
+	if err != nil {
+		return fmt.Errorf("invalid --every: %v", err)
+	}
+	schedule, err := intervalToCronSchedule(every)
+	if err != nil {
+		return err
+	}
+	retention, err := parseSnapshotRetention(*flKeep, *flKeepDaily, *flKeepLast)
+	if err != nil {
+		return err
+	}
+	if *flAccessKey == "" || *flSecretKey == "" {
+		return fmt.Errorf("You must specify the access key and secret key of the cron job with --access-key and --secret-key")
+	}
+
+	ctx := context.Background()
+	if _, err := cli.client.VolumeInspect(ctx, volume); err != nil {
+		return err
+	}
+
+	config := snapshotScheduleJob(volume, *flImage, cli.region, *flAccessKey, *flSecretKey, retention)
+
+	name := *flName
+	if name == "" {
+		name = snapshotScheduleName(volume)
+	}
+	cron := types.Cron{
+		Schedule:   schedule,
+		OwnerEmail: *flMailTo,
+		MailPolicy: "on-failure",
+		Config:     config,
+		HostConfig: &container.HostConfig{
+			NetworkMode: container.NetworkMode("bridge"),
+		},
+		NetConfig: &network.NetworkingConfig{
+			EndpointsConfig: make(map[string]*network.EndpointSettings),
+		},
+		AccessKey: *flAccessKey,
+		SecretKey: *flSecretKey,
+	}
+	if _, err := cli.client.CronCreate(ctx, name, cron); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.out, "Cron %s is created, snapshots of %s will be taken every %s (%s).\n", name, volume, *flEvery, schedule)
+	return nil
+}
+
+// CmdSnapshotPrune removes the scheduled snapshots of a volume that are
+// outside of a retention policy.
+//
+// Usage: hyper snapshot prune [OPTIONS] VOLUME
+func (cli *DockerCli) CmdSnapshotPrune(args ...string) error {
+	cmd := Cli.Subcmd("snapshot prune", []string{"VOLUME"}, "Remove the snapshots of a volume outside of a retention policy.\nWithout policy flags, the policy of the volume snapshot schedule is used.", true)
+	flKeep := cmd.String([]string{"-keep"}, "", "Keep every snapshot younger than this duration (e.g. 48h, 7d, 2w)")
+	flKeepDaily := cmd.Int([]string{"-keep-daily"}, 0, "Keep the last snapshot of each of the last N days")
+	flKeepLast := cmd.Int([]string{"-keep-last"}, 0, "Keep the N most recent snapshots")
+	flAll := cmd.Bool([]string{"a", "-all"}, false, "Also prune the snapshots not taken by a schedule")
+	flDryRun := cmd.Bool([]string{"-dry-run"}, false, "Only show the snapshots that would be removed")
+	flName := cmd.String([]string{"-name"}, "", "Cron name of the snapshot schedule, if the volume has several")
+	cmd.Require(flag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	volume := cmd.Arg(0)
+	ctx := context.Background()
+	retention, err := parseSnapshotRetention(*flKeep, *flKeepDaily, *flKeepLast)
+	if err != nil {
+		return err
+	}
+	if retention.empty() {
+		cron, err := cli.findSnapshotSchedule(ctx, volume, *flName)
+		if err != nil {
+			return fmt.Errorf("No retention policy specified: %v", err)
+		}
+		if retention, err = snapshotRetentionFromLabels(cron.Config.Labels); err != nil {
+			return fmt.Errorf("Invalid retention policy in cron %s: %v", cron.Name, err)
+		}
+		if retention.empty() {
+			return fmt.Errorf("The snapshot schedule of %s has no retention policy", volume)
+		}
+	}
+
+	snapshots, err := cli.client.SnapshotList(ctx, filters.NewArgs())
+	if err != nil {
+		return err
+	}
+	var candidates []*types.Snapshot
+	for _, s := range snapshots.Snapshots {
+		if s.Volume != volume {
+			continue
+		}
+		if !*flAll && !strings.HasPrefix(s.Name, volume+scheduledSnapshotInfix) {
+			continue
+		}
+		candidates = append(candidates, s)
+	}
+
+	status := 0
+	for _, s := range pruneSnapshots(candidates, retention, time.Now()) {
+		if *flDryRun {
+			fmt.Fprintf(cli.out, "Would remove %s\n", s.Name)
+			continue
+		}
+		if err := cli.client.SnapshotRemove(ctx, s.Name); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			status = 1
+			continue
+		}
+		fmt.Fprintf(cli.out, "%s\n", s.Name)
+	}
+
+	if status != 0 {
+		return Cli.StatusError{StatusCode: status}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/hyper-api/types/container"
+)
+
+func TestIntervalToCronSchedule(t *testing.T) {
+	valids := map[string]string{
+		"15m": "*/15 * * * *",
+		"1h":  "0 * * * *",
+		"6h":  "0 */6 * * *",
+		"1d":  "0 0 * * *",
+		"2d":  "0 0 */2 * *",
+		"1w":  "0 0 * * 0",
+	}
+	for every, expected := range valids {
+		d, err := parseLongDuration(every)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schedule, err := intervalToCronSchedule(d)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", every, err)
+		}
+		if schedule != expected {
+			t.Fatalf("Expected %s for %s, got %s", expected, every, schedule)
+		}
+	}
+	for _, every := range []string{"30s", "7m", "5h", "90m", "36h"} {
+		d, err := parseLongDuration(every)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := intervalToCronSchedule(d); err == nil {
+			t.Fatalf("Expected an error for %s", every)
+		}
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	now := time.Date(2016, 10, 20, 12, 0, 0, 0, time.UTC)
+	var snapshots []*types.Snapshot
+	// one snapshot every 6 hours over the last 10 days
+	for i := 0; i < 40; i++ {
+		created := now.Add(-time.Duration(i) * 6 * time.Hour)
+		snapshots = append(snapshots, &types.Snapshot{
+			Name:   "db" + scheduledSnapshotInfix + created.Format(scheduledSnapshotTimeLayout),
+			Volume: "db",
+		})
+	}
+	manual := &types.Snapshot{Name: "before-migration", Volume: "db"}
+	snapshots = append(snapshots, manual)
+
+	names := func(list []*types.Snapshot) []string {
+		var n []string
+		for _, s := range list {
+			n = append(n, s.Name)
+		}
+		sort.Strings(n)
+		return n
+	}
+
+	// keep the last 2 days (9 snapshots) and one per day for 5 days, of
+	// which 3 days are already covered
+	pruned := pruneSnapshots(snapshots, snapshotRetention{Within: 48 * time.Hour, Daily: 5}, now)
+	if len(pruned) != 40-9-2 {
+		t.Fatalf("Expected 29 snapshots to prune, got %d: %v", len(pruned), names(pruned))
+	}
+	for _, s := range pruned {
+		if s == manual {
+			t.Fatal("Snapshots with unknown creation time must be kept")
+		}
+	}
+
+	pruned = pruneSnapshots(snapshots, snapshotRetention{Last: 38}, now)
+	expected := []string{"db-auto-20161010180000", "db-auto-20161011000000"}
+	if !reflect.DeepEqual(names(pruned), expected) {
+		t.Fatalf("Expected %v, got %v", expected, names(pruned))
+	}
+}
+
+func TestSnapshotRetentionLabels(t *testing.T) {
+	r, err := parseSnapshotRetention("7d", 30, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := snapshotRetentionFromLabels(r.labels())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != r {
+		t.Fatalf("Expected %+v, got %+v", r, parsed)
+	}
+}
+
+func TestSnapshotSchedules(t *testing.T) {
+	crons := []types.Cron{
+		{Name: "nightly-db", Config: &container.Config{Labels: map[string]string{snapshotVolumeLabel: "db"}}},
+		{Name: "snapshot-web", Config: &container.Config{Labels: map[string]string{snapshotVolumeLabel: "web"}}},
+		{Name: "backup"},
+	}
+	schedules := snapshotSchedules(crons, "db")
+	if len(schedules) != 1 || schedules[0].Name != "nightly-db" {
+		t.Fatalf("Expected the schedule with a custom name to be found, got %+v", schedules)
+	}
+	if schedules := snapshotSchedules(crons, "logs"); len(schedules) != 0 {
+		t.Fatalf("Expected no schedule, got %+v", schedules)
+	}
+}
+
+func TestSnapshotScheduleJob(t *testing.T) {
+	config := snapshotScheduleJob("db", "hyperhq/hypercli", "us-west-1", "AK", "SK", snapshotRetention{Daily: 7, Last: 3})
+	expectedEnv := []string{"HYPER_DEFAULT_REGION=us-west-1", "HYPER_ACCESS=AK", "HYPER_SECRET=SK"}
+	if !reflect.DeepEqual(config.Env, expectedEnv) {
+		t.Fatalf("Expected the job to get the credentials of the cron, got %v", config.Env)
+	}
+	expectedCmd := "hyper snapshot create --force -v db --name db" + scheduledSnapshotInfix + "$(date -u +%Y%m%d%H%M%S) && hyper snapshot prune --keep-daily 7 --keep-last 3 db"
+	if len(config.Cmd) != 1 || config.Cmd[0] != expectedCmd {
+		t.Fatalf("Expected the command %q, got %v", expectedCmd, config.Cmd)
+	}
+	if config.Labels[snapshotVolumeLabel] != "db" || config.Labels[snapshotKeepDailyLabel] != "7" {
+		t.Fatalf("Unexpected labels %v", config.Labels)
+	}
+
+	config = snapshotScheduleJob("db", "hyperhq/hypercli", "us-west-1", "AK", "SK", snapshotRetention{})
+	if strings.Contains(config.Cmd[0], "prune") {
+		t.Fatalf("Expected no prune without retention policy, got %v", config.Cmd)
+	}
+}
@@ -1,8 +1,10 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"strings"
 	"sync"
 	"time"
@@ -24,8 +26,25 @@ func (cli *DockerCli) CmdStats(args ...string) error {
 	cmd := Cli.Subcmd("stats", []string{"[CONTAINER...]"}, Cli.DockerCommands["stats"].Description, true)
 	all := cmd.Bool([]string{"a", "-all"}, false, "Show all containers (default shows just running)")
 	noStream := cmd.Bool([]string{"-no-stream"}, false, "Disable streaming stats and only pull the first result")
+	format := cmd.String([]string{"-format"}, "", "Pretty-print stats using a Go template")
+	jsonStream := cmd.Bool([]string{"-json"}, false, "Stream one JSON record per line for each new sample")
+	serve := cmd.String([]string{"-serve"}, "", "Serve the stats in the Prometheus format on an address, e.g. :9100")
 
 	cmd.ParseFlags(args, true)
+	if *jsonStream && *format != "" {
+		return fmt.Errorf("--format and --json cannot be combined")
+	}
+	var listener net.Listener
+	if *serve != "" {
+		if *jsonStream || *format != "" || *noStream {
+			return fmt.Errorf("--serve cannot be combined with --format, --json or --no-stream")
+		}
+		var err error
+		if listener, err = net.Listen("tcp", *serve); err != nil {
+			return err
+		}
+		defer listener.Close()
+	}
 
 	names := cmd.Args()
 	showAll := len(names) == 0
@@ -140,13 +159,27 @@ func (cli *DockerCli) CmdStats(args ...string) error {
 	// before print to screen, make sure each container get at least one valid stat data
 	waitFirst.Wait()
 
+	if listener != nil {
+		return cli.serveStats(listener, &cStats, closeChan)
+	}
+
+	f := *format
+	if len(f) == 0 {
+		if len(cli.StatsFormat()) > 0 {
+			f = cli.StatsFormat()
+		} else {
+			f = formatter.TableFormatKey
+		}
+	}
 	statsCtx := formatter.Context{
 		Output: cli.out,
-		Format: formatter.NewStatsFormat(formatter.TableFormatKey),
+		Format: formatter.NewStatsFormat(f),
 	}
+	enc := json.NewEncoder(cli.out)
+	lastRead := make(map[string]time.Time)
 
 	cleanScreen := func() {
-		if !*noStream {
+		if !*noStream && !*jsonStream {
 			fmt.Fprint(cli.out, "\033[2J")
 			fmt.Fprint(cli.out, "\033[H")
 		}
@@ -161,7 +194,12 @@ func (cli *DockerCli) CmdStats(args ...string) error {
 			ccstats = append(ccstats, c.GetStatistics())
 		}
 		cStats.mu.Unlock()
-		if err = formatter.ContainerStatsWrite(statsCtx, ccstats); err != nil {
+		if *jsonStream {
+			err = writeStatsJSON(enc, ccstats, lastRead)
+		} else {
+			err = formatter.ContainerStatsWrite(statsCtx, ccstats)
+		}
+		if err != nil {
 			break
 		}
 		if len(cStats.cs) == 0 && !showAll {
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hyperhq/hypercli/cli/command/formatter"
+	"github.com/hyperhq/libcompose/labels"
+)
+
+// statsMetrics are the metrics exported for each container by `hyper stats
+// --serve`. The network and block IO bytes are cumulative, so they are
+// counters.
+var statsMetrics = []struct {
+	name  string
+	typ   string
+	help  string
+	value func(formatter.StatsEntry) float64
+}{
+	{"hyper_container_cpu_usage_percent", "gauge", "CPU usage of the container in percent.", func(e formatter.StatsEntry) float64 { return e.CPUPercentage }},
+	{"hyper_container_memory_usage_bytes", "gauge", "Memory used by the container.", func(e formatter.StatsEntry) float64 { return e.Memory }},
+	{"hyper_container_memory_limit_bytes", "gauge", "Memory limit of the container.", func(e formatter.StatsEntry) float64 { return e.MemoryLimit }},
+	{"hyper_container_memory_usage_percent", "gauge", "Memory used by the container in percent of its limit.", func(e formatter.StatsEntry) float64 { return e.MemoryPercentage }},
+	{"hyper_container_network_receive_bytes_total", "counter", "Bytes received by the container.", func(e formatter.StatsEntry) float64 { return e.NetworkRx }},
+	{"hyper_container_network_transmit_bytes_total", "counter", "Bytes sent by the container.", func(e formatter.StatsEntry) float64 { return e.NetworkTx }},
+	{"hyper_container_block_read_bytes_total", "counter", "Bytes read by the container from block devices.", func(e formatter.StatsEntry) float64 { return e.BlockRead }},
+	{"hyper_container_block_write_bytes_total", "counter", "Bytes written by the container to block devices.", func(e formatter.StatsEntry) float64 { return e.BlockWrite }},
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusLabels returns the labels of the metrics of a container.
+func prometheusLabels(e formatter.StatsEntry) string {
+	name := e.Name
+	if name == "" {
+		name = e.Container
+	}
+	pairs := [][2]string{
+		{"container", name},
+		{"id", e.ID},
+		{"image", e.Image},
+		{"size", e.Size},
+		{"compose_project", e.Labels[labels.PROJECT.Str()]},
+		{"compose_service", e.Labels[labels.SERVICE.Str()]},
+	}
+	l := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		l = append(l, fmt.Sprintf(`%s="%s"`, p[0], prometheusLabelEscaper.Replace(p[1])))
+	}
+	return "{" + strings.Join(l, ",") + "}"
+}
+
+// writePrometheusStats writes the statistics of the containers in the
+// Prometheus text format. Containers without valid statistics are left
+// out.
+func writePrometheusStats(w io.Writer, entries []formatter.StatsEntry) error {
+	for _, m := range statsMetrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.IsInvalid {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", m.name, prometheusLabels(e), strconv.FormatFloat(m.value(e), 'g', -1, 64)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// statsExporter serves the statistics being collected on /metrics.
+type statsExporter struct {
+	stats *stats
+}
+
+func (e statsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var entries []formatter.StatsEntry
+	e.stats.mu.Lock()
+	for _, c := range e.stats.cs {
+		entries = append(entries, c.GetStatistics())
+	}
+	e.stats.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writePrometheusStats(w, entries)
+}
+
+// serveStats serves the statistics of s in the Prometheus format until
+// the server fails or an error is received on errChan.
+func (cli *DockerCli) serveStats(l net.Listener, s *stats, errChan <-chan error) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", statsExporter{s})
+	fmt.Fprintf(cli.out, "Serving container stats on http://%s/metrics\n", l.Addr())
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- http.Serve(l, mux)
+	}()
+	for {
+		select {
+		case err := <-serveErr:
+			return err
+		case err, ok := <-errChan:
+			if !ok {
+				// no more events to wait for
+				errChan = nil
+				continue
+			}
+			if err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hyperhq/hypercli/cli/command/formatter"
+	"github.com/hyperhq/libcompose/labels"
+)
+
+func TestWritePrometheusStats(t *testing.T) {
+	entries := []formatter.StatsEntry{
+		{
+			Container:     "0123456789ab",
+			Name:          "shop_web_1",
+			ID:            "0123456789abcdef",
+			Image:         "nginx",
+			Size:          "s4",
+			Labels:        map[string]string{labels.PROJECT.Str(): "shop", labels.SERVICE.Str(): "web"},
+			CPUPercentage: 1.5,
+			Memory:        1048576,
+			NetworkRx:     2048,
+		},
+		{Container: "db", Name: `we"ird`, CPUPercentage: 2},
+		{Container: "stopped", IsInvalid: true},
+	}
+	out := bytes.NewBuffer(nil)
+	if err := writePrometheusStats(out, entries); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"# TYPE hyper_container_cpu_usage_percent gauge\n",
+		`hyper_container_cpu_usage_percent{container="shop_web_1",id="0123456789abcdef",image="nginx",size="s4",compose_project="shop",compose_service="web"} 1.5` + "\n",
+		`hyper_container_memory_usage_bytes{container="shop_web_1",id="0123456789abcdef",image="nginx",size="s4",compose_project="shop",compose_service="web"} 1.048576e+06` + "\n",
+		"# TYPE hyper_container_network_receive_bytes_total counter\n",
+		`hyper_container_network_receive_bytes_total{container="shop_web_1",id="0123456789abcdef",image="nginx",size="s4",compose_project="shop",compose_service="web"} 2048` + "\n",
+		`hyper_container_cpu_usage_percent{container="we\"ird",id="",image="",size="",compose_project="",compose_service=""} 2` + "\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("Expected %q in\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "stopped") {
+		t.Fatalf("Expected invalid stats to be left out, got\n%s", out.String())
+	}
+}
@@ -63,6 +63,11 @@ func collect(ctx context.Context, s *formatter.ContainerStats, cli *DockerCli, s
 		}
 	}()
 
+	// the name, image and size of a container never change, get them once
+	if info, err := cli.client.ContainerInspect(ctx, s.Container); err == nil && info.Config != nil {
+		s.SetContainer(info.ID, strings.TrimPrefix(info.Name, "/"), info.Config.Image, info.Config.Labels)
+	}
+
 	responseBody, err := cli.client.ContainerStats(ctx, s.Container, streamStats)
 	if err != nil {
 		s.SetError(err)
@@ -127,6 +132,7 @@ func collect(ctx context.Context, s *formatter.ContainerStats, cli *DockerCli, s
 			netRx, netTx := calculateNetwork(v.Networks)
 
 			s.SetStatistics(formatter.StatsEntry{
+				Read:             v.Read,
 				CPUPercentage:    cpuPercent,
 				Memory:           mem,
 				MemoryPercentage: memPerc,
@@ -179,6 +185,21 @@ func collect(ctx context.Context, s *formatter.ContainerStats, cli *DockerCli, s
 	}
 }
 
+// writeStatsJSON writes the new samples of entries as JSON, one per line.
+// lastRead holds the time of the last sample written for each container.
+func writeStatsJSON(enc *json.Encoder, entries []formatter.StatsEntry, lastRead map[string]time.Time) error {
+	for _, e := range entries {
+		if e.Read.IsZero() || e.Read.Equal(lastRead[e.Container]) {
+			continue
+		}
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		lastRead[e.Container] = e.Read
+	}
+	return nil
+}
+
 func calculateCPUPercent(previousCPU, previousSystem uint64, v *types.StatsJSON) float64 {
 	return float64(v.CPUStats.CPUUsage.TotalUsage) / 100.0
 }
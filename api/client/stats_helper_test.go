@@ -0,0 +1,53 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/hypercli/cli/command/formatter"
+)
+
+func TestWriteStatsJSON(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(out)
+	lastRead := make(map[string]time.Time)
+	read := time.Date(2016, 10, 1, 0, 0, 0, 0, time.UTC)
+	entries := []formatter.StatsEntry{
+		{Container: "web", Size: "s4", Read: read, CPUPercentage: 1.5},
+		{Container: "db", Size: "m1", IsInvalid: true},
+	}
+
+	if err := writeStatsJSON(enc, entries, lastRead); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected one record, got %q", out.String())
+	}
+	var record formatter.StatsEntry
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Container != "web" || record.Size != "s4" || record.CPUPercentage != 1.5 || !record.Read.Equal(read) {
+		t.Fatalf("Unexpected record %+v", record)
+	}
+
+	// the same sample is not written twice
+	out.Reset()
+	if err := writeStatsJSON(enc, entries, lastRead); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("Expected no record, got %q", out.String())
+	}
+	entries[0].Read = read.Add(time.Second)
+	if err := writeStatsJSON(enc, entries, lastRead); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out.String(), "\n") != 1 {
+		t.Fatalf("Expected one new record, got %q", out.String())
+	}
+}
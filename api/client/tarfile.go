@@ -3,10 +3,13 @@ package client
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/cheggaaa/pb"
 )
@@ -29,6 +32,10 @@ type tarInfo struct {
 	headerBuf *bytes.Buffer
 	pos       int64
 	state     int
+
+	// offset and header are filled by Layout for random access reads
+	offset int64
+	header []byte
 }
 
 type TarFile struct {
@@ -40,26 +47,34 @@ type TarFile struct {
 
 	source   string
 	progress *pb.ProgressBar
+
+	// size is the length of the whole tar stream, set by Layout
+	size int64
+}
+
+// tarHeader returns the raw tar header block(s) of a file entry.
+func tarHeader(info *tarInfo) ([]byte, error) {
+	header, err := tar.FileInfoHeader(info.info, info.linkName)
+	if err != nil {
+		return nil, err
+	}
+	header.Name = info.relPath
+
+	buf := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(buf)
+	if err = tarWriter.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 func (t *TarFile) writeHeader(p []byte, info *tarInfo) (int, error) {
 	if info.headerBuf == nil {
-		header, err := tar.FileInfoHeader(info.info, info.linkName)
-		if err != nil {
-			return 0, err
-		}
-		header.Name = info.relPath
-
-		buf := &bytes.Buffer{}
-		tarWriter := tar.NewWriter(buf)
-		defer tarWriter.Close()
-		err = tarWriter.WriteHeader(header)
+		header, err := tarHeader(info)
 		if err != nil {
 			return 0, err
 		}
-
-		info.headerBuf = &bytes.Buffer{}
-		io.Copy(info.headerBuf, buf)
+		info.headerBuf = bytes.NewBuffer(header)
 	}
 
 	size, err := info.headerBuf.Read(p)
@@ -236,6 +251,109 @@ func (t *TarFile) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
+// Layout computes the position of every entry in the tar stream so that
+// it can be read at random offsets with ReadAt. It returns a fingerprint
+// of the stream built from all the entry headers, which changes whenever
+// a file is added, removed, resized or modified.
+func (t *TarFile) Layout() (string, error) {
+	var offset int64
+	hash := sha256.New()
+	for _, info := range t.fileList {
+		header, err := tarHeader(info)
+		if err != nil {
+			return "", err
+		}
+		hash.Write(header)
+		info.header = header
+		info.offset = offset
+		offset += int64(len(header))
+		if info.info.Mode().IsRegular() {
+			offset += info.info.Size() + int64(info.pad)
+		}
+	}
+	t.size = offset + int64(t.blockSize*2)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Size returns the length of the tar stream computed by Layout.
+func (t *TarFile) Size() int64 {
+	return t.size
+}
+
+// ReadAt implements io.ReaderAt over the tar stream. Layout must have been
+// called first.
+func (t *TarFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if off >= t.size {
+		return 0, io.EOF
+	}
+	idx := sort.Search(len(t.fileList), func(i int) bool {
+		return t.fileList[i].offset > off
+	}) - 1
+
+	for n < len(p) && off < t.size {
+		var ret int
+		if idx < 0 || idx >= len(t.fileList) {
+			// trailing zeros of the tar stream
+			ret = len(p) - n
+			if remain := t.size - off; int64(ret) > remain {
+				ret = int(remain)
+			}
+			copy(p[n:n+ret], make([]byte, ret))
+		} else {
+			if ret, err = t.readEntryAt(p[n:], t.fileList[idx], off-t.fileList[idx].offset); err != nil {
+				return n, err
+			}
+			if ret == 0 {
+				idx++
+				continue
+			}
+		}
+		n += ret
+		off += int64(ret)
+	}
+	if n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// readEntryAt copies the bytes of a single entry (header, content and
+// padding) starting at pos into p. It returns 0 once pos is past the entry.
+func (t *TarFile) readEntryAt(p []byte, info *tarInfo, pos int64) (int, error) {
+	headerLen := int64(len(info.header))
+	if pos < headerLen {
+		return copy(p, info.header[pos:]), nil
+	}
+	if !info.info.Mode().IsRegular() {
+		return 0, nil
+	}
+	pos -= headerLen
+	size := info.info.Size()
+	if pos < size {
+		if int64(len(p)) > size-pos {
+			p = p[:size-pos]
+		}
+		f, err := os.Open(info.path)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		ret, err := f.ReadAt(p, pos)
+		if err == io.EOF {
+			if ret < len(p) {
+				return ret, fmt.Errorf("%s changed during upload", info.path)
+			}
+			err = nil
+		}
+		return ret, err
+	}
+	pos -= size
+	if pos < int64(info.pad) {
+		return copy(p, t.padding[pos:info.pad]), nil
+	}
+	return 0, nil
+}
+
 func NewTarFile(path string, blockSize int) *TarFile {
 	return &TarFile{
 		blockSize: blockSize,
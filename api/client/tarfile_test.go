@@ -0,0 +1,87 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTarFileReadAt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tarfile-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]int{
+		"empty":         0,
+		"small":         100,
+		"block":         512,
+		"sub/large":     70000,
+		"sub/deep/file": 1023,
+	}
+	for name, size := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, bytes.Repeat([]byte{byte(size)}, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("small", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tar, err := newVolumeTarFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := tar.Layout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadAll(tar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(expected)) != tar.Size() {
+		t.Fatalf("Expected size %d, got %d", len(expected), tar.Size())
+	}
+
+	for _, chunkSize := range []int{1, 333, 512, 4096, len(expected)} {
+		var got []byte
+		for off := 0; off < len(expected); off += chunkSize {
+			buf := make([]byte, chunkSize)
+			n, err := tar.ReadAt(buf, int64(off))
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			got = append(got, buf[:n]...)
+		}
+		if !bytes.Equal(expected, got) {
+			t.Fatalf("ReadAt with chunk size %d does not match the tar stream", chunkSize)
+		}
+	}
+
+	again, err := newVolumeTarFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := again.Layout(); f != fingerprint {
+		t.Fatalf("Expected a stable fingerprint, got %s and %s", fingerprint, f)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "small"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := newVolumeTarFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := changed.Layout(); f == fingerprint {
+		t.Fatal("Expected the fingerprint to change with the content")
+	}
+}
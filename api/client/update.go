@@ -12,11 +12,13 @@ import (
 	flag "github.com/hyperhq/hypercli/pkg/mflag"
 )
 
-// CmdUpdate updates resources of one or more containers.
+// CmdUpdate updates resources of one or more containers, or hyper itself
+// if no container is given.
 //
-// Usage: hyper update [OPTIONS] CONTAINER [CONTAINER...]
+// Usage: hyper update [OPTIONS] [CONTAINER...]
 func (cli *DockerCli) CmdUpdate(args ...string) error {
-	cmd := Cli.Subcmd("update", []string{"CONTAINER [CONTAINER...]"}, Cli.DockerCommands["update"].Description, true)
+	cmd := Cli.Subcmd("update", []string{"[CONTAINER...]"}, Cli.DockerCommands["update"].Description+".\n\n"+
+		"Without container, check for a newer release of hyper and install it.", true)
 	flAddSecurityGroups := opts.NewListOpts(nil)
 	flRmSecurityGroups := opts.NewListOpts(nil)
 	cmd.Var(&flAddSecurityGroups, []string{"-sg-add"}, "Add a new security group for each container")
@@ -24,8 +26,27 @@ func (cli *DockerCli) CmdUpdate(args ...string) error {
 	// make this flag string type to distinguish between 'not set', 'set to false' and 'set to true'
 	flContainerProtection := cmd.String([]string{"-protection"}, "", "Termination protection for container (true|false)")
 
-	cmd.Require(flag.Min, 1)
+	flCheck := cmd.Bool([]string{"-check"}, false, "Only check for a newer release of hyper")
+	flChannel := cmd.String([]string{"-channel"}, "", "Release channel to update hyper from (stable|beta)")
+
+	cmd.Require(flag.Min, 0)
 	cmd.ParseFlags(args, true)
+	selfFlags := 0
+	if *flCheck {
+		selfFlags++
+	}
+	if *flChannel != "" {
+		selfFlags++
+	}
+	if cmd.NArg() == 0 {
+		if cmd.NFlag() != selfFlags {
+			return fmt.Errorf("\"update\" requires at least 1 container to update the resources of")
+		}
+		return cli.selfUpdate(*flChannel, *flCheck)
+	}
+	if selfFlags > 0 {
+		return fmt.Errorf("--check and --channel can't be used with containers")
+	}
 	if cmd.NFlag() == 0 {
 		return fmt.Errorf("You must provide one or more flags when using this command.")
 	}
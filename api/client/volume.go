@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,13 +10,18 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/hyperhq/hypercli/api/client/formatter"
 	Cli "github.com/hyperhq/hypercli/cli"
 	"github.com/hyperhq/hypercli/opts"
+	"github.com/hyperhq/hypercli/pkg/archive"
 	flag "github.com/hyperhq/hypercli/pkg/mflag"
+	"github.com/hyperhq/hypercli/pkg/progress"
+	"github.com/hyperhq/hypercli/pkg/streamformatter"
 
 	"github.com/cheggaaa/pb"
+	"github.com/hyperhq/hyper-api/client"
 	"github.com/hyperhq/hyper-api/types"
 	"github.com/hyperhq/hyper-api/types/filters"
 	"golang.org/x/net/context"
@@ -28,10 +34,12 @@ func (cli *DockerCli) CmdVolume(args ...string) error {
 	description := Cli.DockerCommands["volume"].Description + "\n\nCommands:\n"
 	commands := [][]string{
 		{"create", "Create a volume"},
+		{"export", "Export the content of a volume as a tar archive"},
 		{"inspect", "Return low-level information on a volume"},
 		{"ls", "List volumes"},
 		{"init", "Initialize volumes"},
 		{"rm", "Remove a volume"},
+		{"sync", "Push the changes of a local directory into a volume"},
 	}
 
 	for _, cmd := range commands {
@@ -57,6 +65,7 @@ func (cli *DockerCli) CmdVolumeLs(args ...string) error {
 	format := cmd.String([]string{"-format"}, "", "Pretty-print containers using a Go template")
 	flFilter := opts.NewListOpts(nil)
 	cmd.Var(&flFilter, []string{"f", "-filter"}, "Provide filter values (i.e. 'dangling=true')")
+	flRegion := addRegionFlags(cmd)
 
 	cmd.Require(flag.Exact, 0)
 	cmd.ParseFlags(args, true)
@@ -70,10 +79,28 @@ func (cli *DockerCli) CmdVolumeLs(args ...string) error {
 		}
 	}
 
-	volumes, err := cli.client.VolumeList(context.Background(), volFilterArgs)
+	regions, err := flRegion.list()
 	if err != nil {
 		return err
 	}
+	lists := make([]types.VolumesListResponse, regionCount(regions))
+	err = cli.forEachRegion(regions, func(i int, region string, c client.APIClient) error {
+		volumes, err := c.VolumeList(context.Background(), volFilterArgs)
+		lists[i] = volumes
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	var (
+		volumes types.VolumesListResponse
+		lengths []int
+	)
+	for _, list := range lists {
+		volumes.Volumes = append(volumes.Volumes, list.Volumes...)
+		volumes.Warnings = append(volumes.Warnings, list.Warnings...)
+		lengths = append(lengths, len(list.Volumes))
+	}
 
 	/*
 		w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
@@ -118,6 +145,7 @@ func (cli *DockerCli) CmdVolumeLs(args ...string) error {
 			Quiet:  *quiet,
 		},
 		Volumes: volumes.Volumes,
+		Regions: listingRegions(regions, lengths),
 	}
 
 	volCtx.Write()
@@ -209,6 +237,61 @@ func (cli *DockerCli) CmdVolumeRm(args ...string) error {
 	return nil
 }
 
+// CmdVolumeExport streams the content of a volume to a local tar archive
+// or directory, through a temporary helper container.
+//
+// Usage: hyper volume export [OPTIONS] VOLUME
+func (cli *DockerCli) CmdVolumeExport(args ...string) error {
+	cmd := Cli.Subcmd("volume export", []string{"VOLUME"}, "Export the content of a volume as a tar archive (streamed to STDOUT by default)", true)
+	outfile := cmd.String([]string{"o", "-output"}, "", "Write to a tar file, instead of STDOUT")
+	todir := cmd.String([]string{"-to"}, "", "Extract the content into a local directory")
+	image := cmd.String([]string{"-image"}, defaultVolumeHelperImage, "Image of the temporary helper container")
+	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Do not show export progress")
+	cmd.Require(flag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	if *outfile != "" && *todir != "" {
+		return fmt.Errorf("Conflicting options: -o and --to")
+	}
+	if *outfile == "" && *todir == "" && cli.isTerminalOut {
+		return errors.New("Cowardly refusing to save to a terminal. Use the -o or --to flag or redirect.")
+	}
+
+	ctx := context.Background()
+	helper, err := cli.startVolumeHelper(ctx, cmd.Arg(0), *image)
+	if err != nil {
+		return err
+	}
+	defer helper.Remove(ctx)
+
+	content, _, err := cli.client.CopyFromContainer(ctx, helper.ID, volumeHelperMountPoint)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	// Strip the mount point so that the archive holds the volume content at its root
+	var input io.ReadCloser = archive.RebaseArchiveEntries(content, filepath.Base(volumeHelperMountPoint), ".")
+	if !*quiet {
+		progressOutput := streamformatter.NewStreamFormatter().NewProgressOutput(cli.err, false)
+		input = progress.NewProgressReader(input, progressOutput, 0, cmd.Arg(0), "Exporting")
+		defer fmt.Fprintln(cli.err)
+	}
+
+	switch {
+	case *todir != "":
+		if err := os.MkdirAll(*todir, 0755); err != nil {
+			return err
+		}
+		return archive.Untar(input, *todir, &archive.TarOptions{NoLchown: true})
+	case *outfile != "":
+		return copyToFile(*outfile, input)
+	default:
+		_, err = io.Copy(cli.out, input)
+		return err
+	}
+}
+
 func validateVolumeSource(source string) error {
 	switch {
 	case strings.HasPrefix(source, "git://"):
@@ -262,13 +345,31 @@ func validateVolumeInitArgs(args []string, req *types.VolumesInitializeRequest)
 // Usage: docker volume init SOURCE:VOLUME [SOURCE:VOLUME...]
 func (cli *DockerCli) CmdVolumeInit(args ...string) error {
 	cmd := Cli.Subcmd("volume init", []string{"SOURCE:VOLUME [SOURCE:VOLUME...]"}, "Initialize a volume", true)
+	flChunkSize := cmd.String([]string{"-chunk-size"}, "", "Upload local sources in resumable chunks of this size, e.g. 32M, if the server supports it (default uploads in a single request)")
+	flParallel := cmd.Int([]string{"-parallel"}, defaultVolumeParallel, "Number of chunks of a volume uploaded in parallel, with --chunk-size")
+	flRetries := cmd.Int([]string{"-retries"}, defaultVolumeRetries, "Number of retries of a failed chunk, with --chunk-size")
+	flBwLimit := cmd.String([]string{"-bwlimit"}, "", "Limit the upload bandwidth, e.g. 10M for 10 MiB/s")
 	cmd.Require(flag.Min, 1)
 	cmd.ParseFlags(args, true)
 
-	return cli.initVolumes(cmd.Args(), false)
+	if *flChunkSize == "" && (cmd.IsSet("-parallel") || cmd.IsSet("-retries")) {
+		return fmt.Errorf("--parallel and --retries can only be used with --chunk-size")
+	}
+	opts, err := parseVolumeUploadOptions(*flChunkSize, *flParallel, *flRetries, *flBwLimit)
+	if err != nil {
+		return err
+	}
+	return cli.initVolumes(cmd.Args(), false, opts)
 }
 
-func (cli *DockerCli) initVolumes(vols []string, reload bool) error {
+// initVolumes initializes the volumes described by SOURCE:VOLUME args and
+// uploads the local sources. With a non zero chunk size, an interrupted
+// upload is recorded under ~/.hyper/uploads and resumed by the next call
+// with the same args. A nil opts uses the default upload options.
+func (cli *DockerCli) initVolumes(vols []string, reload bool, opts *volumeUploadOptions) error {
+	if opts == nil {
+		opts = defaultVolumeUploadOptions()
+	}
 	var req types.VolumesInitializeRequest
 	pathType, err := validateVolumeInitArgs(vols, &req)
 	if err != nil {
@@ -276,17 +377,76 @@ func (cli *DockerCli) initVolumes(vols []string, reload bool) error {
 	}
 	ctx := context.Background()
 	req.Reload = reload
-	resp, err := cli.client.VolumeInitialize(ctx, req)
-	if err != nil {
-		return err
+
+	sources := make(map[string]string)
+	for idx, desc := range req.Volume {
+		sources[desc.Name] = recoverPath(pathType[idx], desc.Source)
 	}
 
-	if len(resp.Session) == 0 {
-		return nil
+	chunked := opts.chunkSize > 0
+	if chunked && !cli.chunkedVolumeUpload(ctx) {
+		return fmt.Errorf("--chunk-size is not supported by the server")
+	}
+	tars := make(map[string]*TarFile)
+	session, err := loadVolumeUploadSession(volumeUploadSessionPath(vols))
+	if err != nil || reload || !chunked {
+		session = nil
+	} else if !cli.resumableVolumeUpload(session, sources, tars) {
+		session.remove()
+		session = nil
+	}
+	resumed := session != nil
+
+	if !resumed {
+		resp, err := cli.client.VolumeInitialize(ctx, req)
+		if err != nil {
+			return err
+		}
+		if len(resp.Session) == 0 {
+			return nil
+		}
+		session = &volumeUploadSession{
+			Session: resp.Session,
+			Cookie:  resp.Cookie,
+			Created: time.Now(),
+			Volumes: make(map[string]*volumeUploadState),
+			path:    volumeUploadSessionPath(vols),
+		}
+		for name, url := range resp.Uploaders {
+			tar, err := newVolumeTarFile(sources[name])
+			if err != nil {
+				return err
+			}
+			tars[name] = tar
+			state := &volumeUploadState{
+				Name:      name,
+				Source:    sources[name],
+				URL:       url,
+				ChunkSize: opts.chunkSize,
+			}
+			if chunked {
+				if state.Fingerprint, err = tar.Layout(); err != nil {
+					return err
+				}
+				state.Size = tar.Size()
+			}
+			session.Volumes[name] = state
+		}
+		if chunked && len(session.Volumes) > 0 {
+			if err := session.save(); err != nil {
+				fmt.Fprintf(cli.err, "Warning: upload will not be resumable: %s\n", err.Error())
+			}
+		}
+	} else {
+		fmt.Fprintf(cli.out, "Resuming upload session %s\n", session.Session)
 	}
+
 	// Upload local volumes
-	var wg sync.WaitGroup
-	var results []error
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results []error
+	)
 	pool, err := pb.StartPool()
 	if err != nil {
 		// Ignore progress bar failures
@@ -294,12 +454,30 @@ func (cli *DockerCli) initVolumes(vols []string, reload bool) error {
 		pool = nil
 		err = nil
 	}
-	for idx, desc := range req.Volume {
-		if url, ok := resp.Uploaders[desc.Name]; ok {
-			source := recoverPath(pathType[idx], desc.Source)
-			wg.Add(1)
-			go uploadLocalVolume(source, url, resp.Cookie, &results, &wg, pool)
-		}
+	for name, state := range session.Volumes {
+		wg.Add(1)
+		go func(tar *TarFile, state *volumeUploadState) {
+			defer wg.Done()
+			var err error
+			if chunked {
+				var bar *pb.ProgressBar
+				if pool != nil {
+					bar = pb.New64(state.Size).SetUnits(pb.U_BYTES).Prefix(fmt.Sprintf("Sending %s", tar.source))
+					pool.Add(bar)
+				}
+				err = uploadChunks(tar, state, session, opts, bar)
+				if bar != nil {
+					bar.Finish()
+				}
+			} else {
+				err = uploadLocalVolume(tar, state.URL, session.Cookie, pool, opts.limiter)
+			}
+			if err != nil {
+				mu.Lock()
+				results = append(results, err)
+				mu.Unlock()
+			}
+		}(tars[name], state)
 	}
 
 	wg.Wait()
@@ -310,34 +488,58 @@ func (cli *DockerCli) initVolumes(vols []string, reload bool) error {
 		fmt.Fprintf(cli.err, "Upload local volume failed: %s\n", err.Error())
 	}
 
-	finishErr := cli.client.VolumeUploadFinish(ctx, resp.Session)
+	if chunked && len(results) > 0 {
+		for _, e := range results {
+			if resumed && sessionExpired(e) {
+				session.remove()
+				return fmt.Errorf("Upload session %s expired, please run the command again", session.Session)
+			}
+		}
+		return fmt.Errorf("Upload interrupted, run the same command again to resume it")
+	}
+
+	finishErr := cli.client.VolumeUploadFinish(ctx, session.Session)
 	if err == nil {
 		err = finishErr
 	}
+	if err == nil {
+		session.remove()
+	}
 	return err
 }
 
-func uploadLocalVolume(source, url, cookie string, results *[]error, wg *sync.WaitGroup, pool *pb.Pool) {
-	var (
-		resp     io.ReadCloser
-		tar      *TarFile
-		fullPath string
-		err      error
-	)
-
-	defer func() {
+// resumableVolumeUpload checks that the local sources did not change since
+// session was saved, and prepares their tar files in tars.
+func (cli *DockerCli) resumableVolumeUpload(session *volumeUploadSession, sources map[string]string, tars map[string]*TarFile) bool {
+	if len(session.Volumes) == 0 {
+		return false
+	}
+	for name, state := range session.Volumes {
+		if sources[name] != state.Source || state.ChunkSize <= 0 {
+			return false
+		}
+		tar, err := newVolumeTarFile(state.Source)
 		if err != nil {
-			*results = append(*results, err)
+			return false
+		}
+		fingerprint, err := tar.Layout()
+		if err != nil || fingerprint != state.Fingerprint || tar.Size() != state.Size {
+			fmt.Fprintf(cli.err, "Local source %s changed since the interrupted upload, starting over\n", state.Source)
+			return false
 		}
-		wg.Done()
-	}()
+		tars[name] = tar
+	}
+	return true
+}
 
-	fullPath, err = filepath.Abs(source)
+// newVolumeTarFile walks source and returns a tar stream of its content.
+func newVolumeTarFile(source string) (*TarFile, error) {
+	fullPath, err := filepath.Abs(source)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	tar = NewTarFile(source, 512)
+	tar := NewTarFile(source, 512)
 	walkFunc := func(path string, info os.FileInfo, err error) error {
 		var relPath, linkName string
 
@@ -368,19 +570,28 @@ func uploadLocalVolume(source, url, cookie string, results *[]error, wg *sync.Wa
 		return nil
 	}
 
-	err = filepath.Walk(fullPath, walkFunc)
-	if err != nil {
-		return
+	if err = filepath.Walk(fullPath, walkFunc); err != nil {
+		return nil, err
 	}
+	return tar, nil
+}
+
+// uploadLocalVolume sends the whole tar stream in a single request, at
+// most at the rate of limiter if not nil.
+func uploadLocalVolume(tar *TarFile, url, cookie string, pool *pb.Pool, limiter *rateLimiter) error {
 	if pool != nil {
 		tar.AllocBar(pool)
 	}
 
-	resp, err = sendTarball(url, cookie, tar)
+	var input io.ReadCloser = tar
+	if limiter != nil {
+		input = readCloser{&rateLimitedReader{r: tar, limiter: limiter}, tar}
+	}
+	resp, err := sendTarball(url, cookie, input)
 	if err != nil {
-		return
+		return err
 	}
-	defer resp.Close()
+	return resp.Close()
 }
 
 func sendTarball(uri, cookie string, input io.ReadCloser) (io.ReadCloser, error) {
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	gosignal "os/signal"
+	"strings"
+	"sync"
+
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/hyper-api/types/container"
+	"github.com/hyperhq/hyper-api/types/network"
+	"github.com/hyperhq/hyper-api/types/strslice"
+	"github.com/hyperhq/hypercli/pkg/stringid"
+	"golang.org/x/net/context"
+)
+
+const (
+	// defaultVolumeHelperImage is the image of the temporary containers
+	// used to access the content of a volume.
+	defaultVolumeHelperImage = "busybox"
+	// volumeHelperMountPoint is where the volume is mounted in the helper.
+	volumeHelperMountPoint = "/volume"
+)
+
+// volumeHelper is a tiny temporary container with a volume mounted at
+// volumeHelperMountPoint, used to read or write the volume content.
+type volumeHelper struct {
+	cli    *DockerCli
+	ID     string
+	Volume string
+
+	sigc chan os.Signal
+	// done is closed by Remove, to stop waiting for an interrupt.
+	done chan struct{}
+	once sync.Once
+}
+
+// startVolumeHelper creates and starts a helper container for volume.
+// The caller must call Remove once done; the container is also removed
+// if the command is interrupted.
+func (cli *DockerCli) startVolumeHelper(ctx context.Context, volume, image string) (*volumeHelper, error) {
+	if image == "" {
+		image = defaultVolumeHelperImage
+	}
+	if _, err := cli.client.VolumeInspect(ctx, volume); err != nil {
+		return nil, err
+	}
+	if _, _, err := cli.client.ImageInspectWithRaw(ctx, image, false); err != nil && strings.Contains(err.Error(), "No such image") {
+		if err := cli.pullImage(ctx, image); err != nil {
+			return nil, err
+		}
+	}
+
+	config := &container.Config{
+		Image: image,
+		Cmd:   strslice.StrSlice{"sleep", "86400"},
+		Labels: map[string]string{
+			"sh_hyper_instancetype":  "s1",
+			"sh_hyper_noauto_volume": "true",
+		},
+	}
+	hostConfig := &container.HostConfig{
+		Binds: []string{volume + ":" + volumeHelperMountPoint},
+	}
+	name := fmt.Sprintf("volume-helper-%s-%s", volume, stringid.GenerateRandomID()[:8])
+	resp, err := cli.client.ContainerCreate(ctx, config, hostConfig, &network.NetworkingConfig{}, name)
+	if err != nil {
+		return nil, err
+	}
+	h := &volumeHelper{
+		cli:    cli,
+		ID:     resp.ID,
+		Volume: volume,
+		sigc:   make(chan os.Signal, 1),
+		done:   make(chan struct{}),
+	}
+	gosignal.Notify(h.sigc, os.Interrupt)
+	go func() {
+		select {
+		case <-h.sigc:
+			// waits for a Remove already running on the main path
+			h.Remove(context.Background())
+			os.Exit(130)
+		case <-h.done:
+		}
+	}()
+
+	if err := cli.client.ContainerStart(ctx, resp.ID, ""); err != nil {
+		h.Remove(ctx)
+		return nil, err
+	}
+	return h, nil
+}
+
+// Remove deletes the helper container, leaving the volume untouched. It
+// is safe to call several times, and concurrently.
+func (h *volumeHelper) Remove(ctx context.Context) {
+	h.once.Do(func() {
+		gosignal.Stop(h.sigc)
+		close(h.done)
+		options := types.ContainerRemoveOptions{
+			RemoveVolumes: false,
+			Force:         true,
+		}
+		if _, err := h.cli.client.ContainerRemove(ctx, h.ID, options); err != nil {
+			fmt.Fprintf(h.cli.err, "Warning: failed to remove helper container %s: %v\n", stringid.TruncateID(h.ID), err)
+		}
+	})
+}
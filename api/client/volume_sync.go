@@ -0,0 +1,370 @@
+package client
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperhq/hyper-api/types"
+	Cli "github.com/hyperhq/hypercli/cli"
+	"github.com/hyperhq/hypercli/pkg/filenotify"
+	flag "github.com/hyperhq/hypercli/pkg/mflag"
+	"golang.org/x/net/context"
+)
+
+const (
+	// syncManifestName is the file kept at the root of a synced volume,
+	// describing the content of the last sync.
+	syncManifestName = ".hyper-sync.json"
+	// syncDebounce is how long --watch waits for changes to settle
+	// before syncing.
+	syncDebounce = 500 * time.Millisecond
+	// maxSyncRemoveArgs is the number of paths removed by a single exec.
+	maxSyncRemoveArgs = 100
+)
+
+// syncEntry describes one file, directory or symlink of a synced tree.
+type syncEntry struct {
+	Mode   os.FileMode
+	Size   int64  `json:",omitempty"`
+	Sha256 string `json:",omitempty"`
+	Link   string `json:",omitempty"`
+}
+
+// syncManifest maps slash separated paths, relative to the volume root,
+// to their description.
+type syncManifest struct {
+	Files map[string]syncEntry
+}
+
+// diffSyncManifest returns the paths of local that are new or differ from
+// remote, parents first, and the paths of remote that no longer exist
+// locally, children first.
+func diffSyncManifest(remote, local *syncManifest) (changed, removed []string) {
+	for p, entry := range local.Files {
+		if old, ok := remote.Files[p]; !ok || old != entry {
+			changed = append(changed, p)
+		}
+	}
+	for p := range remote.Files {
+		if _, ok := local.Files[p]; !ok {
+			removed = append(removed, p)
+		}
+	}
+	sort.Strings(changed)
+	sort.Sort(sort.Reverse(sort.StringSlice(removed)))
+	return changed, removed
+}
+
+type syncHashCache struct {
+	modTime time.Time
+	size    int64
+	sum     string
+}
+
+// syncScanner builds the manifest of a local directory, caching file
+// hashes by modification time and size so that repeated scans in
+// --watch mode only read the files that changed.
+type syncScanner struct {
+	root  string
+	cache map[string]syncHashCache
+}
+
+func (s *syncScanner) scan() (*syncManifest, error) {
+	m := &syncManifest{Files: make(map[string]syncEntry)}
+	cache := make(map[string]syncHashCache)
+	err := filepath.Walk(s.root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." || rel == syncManifestName {
+			return nil
+		}
+
+		entry := syncEntry{Mode: info.Mode()}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if entry.Link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			entry.Size = info.Size()
+			c, ok := s.cache[rel]
+			if !ok || !c.modTime.Equal(info.ModTime()) || c.size != info.Size() {
+				c = syncHashCache{modTime: info.ModTime(), size: info.Size()}
+				if c.sum, err = hashFile(p); err != nil {
+					return err
+				}
+			}
+			cache[rel] = c
+			entry.Sha256 = c.sum
+		case info.IsDir():
+		default:
+			// sockets, devices and pipes can not be synced
+			return nil
+		}
+		m.Files[rel] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.cache = cache
+	return m, nil
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeSyncTar writes a tar archive with the changed paths followed by the
+// new manifest.
+func writeSyncTar(w io.Writer, root string, changed []string, manifest *syncManifest) error {
+	tw := tar.NewWriter(w)
+	for _, rel := range changed {
+		entry := manifest.Files[rel]
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		info, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, entry.Link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if !info.Mode().IsRegular() {
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			continue
+		}
+		// Use the size the hash was computed with, a file modified in
+		// the meantime is caught by the next sync.
+		hdr.Size = entry.Size
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(tw, f, entry.Size)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s changed during sync: %v", p, err)
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:     syncManifestName,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// readSyncManifest fetches the manifest of the last sync from the volume
+// mounted in helper. A volume never synced has an empty manifest.
+func (cli *DockerCli) readSyncManifest(ctx context.Context, helper *volumeHelper) (*syncManifest, error) {
+	m := &syncManifest{Files: make(map[string]syncEntry)}
+	content, _, err := cli.client.CopyFromContainer(ctx, helper.ID, path.Join(volumeHelperMountPoint, syncManifestName))
+	if err != nil {
+		if isPathNotFound(err) {
+			// No manifest yet, everything has to be uploaded
+			return m, nil
+		}
+		return nil, fmt.Errorf("Error reading the sync manifest of volume %s: %v", helper.Volume, err)
+	}
+	defer content.Close()
+	tr := tar.NewReader(content)
+	if _, err := tr.Next(); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid sync manifest in volume %s: %v", helper.Volume, err)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]syncEntry)
+	}
+	return m, nil
+}
+
+// isPathNotFound reports whether err is the error of the server for a path
+// missing from a container.
+func isPathNotFound(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "Could not find the file") || strings.Contains(msg, "no such file or directory")
+}
+
+// syncVolume pushes the differences between the local manifest and the
+// remote one, and returns the new remote manifest.
+func (cli *DockerCli) syncVolume(ctx context.Context, helper *volumeHelper, scanner *syncScanner, remote *syncManifest) (*syncManifest, error) {
+	local, err := scanner.scan()
+	if err != nil {
+		return nil, err
+	}
+	changed, removed := diffSyncManifest(remote, local)
+	if len(changed) == 0 && len(removed) == 0 {
+		return remote, nil
+	}
+
+	for i := 0; i < len(removed); i += maxSyncRemoveArgs {
+		end := i + maxSyncRemoveArgs
+		if end > len(removed) {
+			end = len(removed)
+		}
+		cmd := []string{"rm", "-rf", "--"}
+		for _, rel := range removed[i:end] {
+			cmd = append(cmd, path.Join(volumeHelperMountPoint, rel))
+		}
+		execID, err := cli.ExecCmd(ctx, "", helper.ID, cmd)
+		if err != nil {
+			return nil, err
+		}
+		if err := cli.WaitExec(ctx, execID); err != nil {
+			return nil, err
+		}
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeSyncTar(pw, scanner.root, changed, local))
+	}()
+	options := types.CopyToContainerOptions{
+		AllowOverwriteDirWithFile: true,
+	}
+	err = cli.client.CopyToContainer(ctx, helper.ID, volumeHelperMountPoint, pr, options)
+	pr.Close()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(cli.out, "%s synced: %d updated, %d removed\n", time.Now().Format("15:04:05"), len(changed), len(removed))
+	return local, nil
+}
+
+// CmdVolumeSync incrementally pushes a local directory into a volume.
+//
+// Usage: hyper volume sync [OPTIONS] LOCALDIR VOLUME
+func (cli *DockerCli) CmdVolumeSync(args ...string) error {
+	cmd := Cli.Subcmd("volume sync", []string{"LOCALDIR VOLUME"}, "Push the changes of a local directory into a volume", true)
+	watch := cmd.Bool([]string{"w", "-watch"}, false, "Keep watching the directory and sync every change")
+	image := cmd.String([]string{"-image"}, defaultVolumeHelperImage, "Image of the temporary helper container")
+	cmd.Require(flag.Exact, 2)
+	cmd.ParseFlags(args, true)
+
+	root, err := filepath.Abs(cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(root); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", cmd.Arg(0))
+	}
+
+	ctx := context.Background()
+	helper, err := cli.startVolumeHelper(ctx, cmd.Arg(1), *image)
+	if err != nil {
+		return err
+	}
+	defer helper.Remove(ctx)
+
+	remote, err := cli.readSyncManifest(ctx, helper)
+	if err != nil {
+		return err
+	}
+	scanner := &syncScanner{root: root}
+	if remote, err = cli.syncVolume(ctx, helper, scanner, remote); err != nil {
+		return err
+	}
+	if !*watch {
+		return nil
+	}
+
+	watcher, err := filenotify.New()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	watchDirs := func() error {
+		return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err == nil && info.IsDir() {
+				err = watcher.Add(p)
+			}
+			return err
+		})
+	}
+	if err := watchDirs(); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.out, "Watching %s for changes, press Ctrl-C to stop\n", root)
+
+	var timer <-chan time.Time
+	for {
+		select {
+		case e := <-watcher.Events():
+			if filepath.Base(e.Name) == syncManifestName && filepath.Dir(e.Name) == root {
+				continue
+			}
+			timer = time.After(syncDebounce)
+		case err := <-watcher.Errors():
+			return err
+		case <-timer:
+			timer = nil
+			// New directories have to be watched too
+			if err := watchDirs(); err != nil {
+				fmt.Fprintf(cli.err, "Error watching %s: %v\n", root, err)
+			}
+			synced, err := cli.syncVolume(ctx, helper, scanner, remote)
+			if err != nil {
+				fmt.Fprintf(cli.err, "Sync failed, retrying on next change: %v\n", err)
+				continue
+			}
+			remote = synced
+		}
+	}
+}
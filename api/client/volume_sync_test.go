@@ -0,0 +1,101 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffSyncManifest(t *testing.T) {
+	remote := &syncManifest{Files: map[string]syncEntry{
+		"a":        {Mode: os.ModeDir | 0755},
+		"a/same":   {Mode: 0644, Size: 1, Sha256: "1"},
+		"a/old":    {Mode: 0644, Size: 1, Sha256: "2"},
+		"b":        {Mode: os.ModeDir | 0755},
+		"b/c":      {Mode: os.ModeDir | 0755},
+		"b/c/gone": {Mode: 0644, Size: 1, Sha256: "3"},
+	}}
+	local := &syncManifest{Files: map[string]syncEntry{
+		"a":      {Mode: os.ModeDir | 0755},
+		"a/same": {Mode: 0644, Size: 1, Sha256: "1"},
+		"a/old":  {Mode: 0644, Size: 2, Sha256: "4"},
+		"a/new":  {Mode: os.ModeSymlink | 0777, Link: "same"},
+	}}
+
+	changed, removed := diffSyncManifest(remote, local)
+	if expected := []string{"a/new", "a/old"}; !reflect.DeepEqual(changed, expected) {
+		t.Fatalf("Expected changed %v, got %v", expected, changed)
+	}
+	if expected := []string{"b/c/gone", "b/c", "b"}; !reflect.DeepEqual(removed, expected) {
+		t.Fatalf("Expected removed %v, got %v", expected, removed)
+	}
+}
+
+func TestSyncScannerAndTar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volume-sync-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", "file"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := &syncScanner{root: dir}
+	manifest, err := scanner.scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := manifest.Files["sub/file"]
+	if !ok || entry.Size != 5 || entry.Sha256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Fatalf("Unexpected entry for sub/file: %+v", entry)
+	}
+
+	changed, _ := diffSyncManifest(&syncManifest{Files: map[string]syncEntry{}}, manifest)
+	buf := &bytes.Buffer{}
+	if err := writeSyncTar(buf, dir, changed, manifest); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if expected := []string{"sub/", "sub/file", syncManifestName}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected tar entries %v, got %v", expected, names)
+	}
+
+	again, err := scanner.scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, removed := diffSyncManifest(manifest, again); len(changed) != 0 || len(removed) != 0 {
+		t.Fatalf("Expected no changes, got %v and %v", changed, removed)
+	}
+}
+
+func TestIsPathNotFound(t *testing.T) {
+	if !isPathNotFound(errors.New("Error response from daemon: Could not find the file /volume/.hyper-sync in container foo")) {
+		t.Fatal("expected a missing manifest to be not found")
+	}
+	if isPathNotFound(errors.New("Error response from daemon: An error occurred trying to connect: EOF")) {
+		t.Fatal("expected a transient error not to be not found")
+	}
+}
@@ -0,0 +1,413 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb"
+	"github.com/docker/go-units"
+	"github.com/hyperhq/hyper-api/types/versions"
+	"github.com/hyperhq/hypercli/cliconfig"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultVolumeParallel = 4
+	defaultVolumeRetries  = 5
+
+	maxUploadRetryDelay = 30 * time.Second
+
+	// statusTooManyRequests is http.StatusTooManyRequests, which Go 1.5
+	// lacks.
+	statusTooManyRequests = 429
+
+	// minChunkedVolumeUploadAPIVersion is the first API version of the
+	// server whose uploaders accept volumes in chunks.
+	minChunkedVolumeUploadAPIVersion = "1.24"
+)
+
+// chunkedVolumeUpload reports whether the uploaders of the server accept
+// volumes in chunks.
+func (cli *DockerCli) chunkedVolumeUpload(ctx context.Context) bool {
+	version, err := cli.client.ServerVersion(ctx)
+	return err == nil && !versions.LessThan(version.APIVersion, minChunkedVolumeUploadAPIVersion)
+}
+
+// volumeUploadOptions controls how local volume sources are uploaded.
+type volumeUploadOptions struct {
+	// chunkSize is the size of each upload request, 0 sends the whole
+	// tarball in a single request. Chunked uploads need a server
+	// supporting them, so they are only used when asked for.
+	chunkSize int64
+	// parallel is the number of chunks of a volume uploaded at a time.
+	parallel int
+	// retries is the number of times a failed chunk is retried, a single
+	// request is not retried.
+	retries int
+	// limiter caps the bandwidth of all the uploads, chunked or not, nil
+	// means unlimited.
+	limiter *rateLimiter
+}
+
+func defaultVolumeUploadOptions() *volumeUploadOptions {
+	return &volumeUploadOptions{
+		parallel: defaultVolumeParallel,
+		retries:  defaultVolumeRetries,
+	}
+}
+
+// parseVolumeUploadOptions builds the upload options from the `volume init` flags.
+func parseVolumeUploadOptions(chunkSize string, parallel, retries int, bwlimit string) (*volumeUploadOptions, error) {
+	opts := defaultVolumeUploadOptions()
+	if chunkSize != "" {
+		size, err := units.RAMInBytes(chunkSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk size %q: %v", chunkSize, err)
+		}
+		if size < 0 {
+			return nil, fmt.Errorf("invalid chunk size %q", chunkSize)
+		}
+		opts.chunkSize = size
+	}
+	if parallel < 1 {
+		return nil, fmt.Errorf("parallel must be at least 1")
+	}
+	opts.parallel = parallel
+	if retries < 0 {
+		return nil, fmt.Errorf("retries must not be negative")
+	}
+	opts.retries = retries
+	if bwlimit != "" {
+		rate, err := units.RAMInBytes(bwlimit)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid bandwidth limit %q", bwlimit)
+		}
+		opts.limiter = newRateLimiter(rate)
+	}
+	return opts, nil
+}
+
+// rateLimiter spreads reads over time so that the total throughput of
+// all the readers sharing it stays below the configured rate.
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate float64 // bytes per second
+	next time.Time
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{rate: float64(bytesPerSecond)}
+}
+
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+type rateLimitedReader struct {
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+	return n, err
+}
+
+// volumeUploadState is the part of a resumable upload session that is
+// specific to one volume.
+type volumeUploadState struct {
+	Name        string
+	Source      string
+	URL         string
+	Fingerprint string
+	Size        int64
+	ChunkSize   int64
+	Uploaded    []int
+}
+
+// volumeUploadSession is persisted under ~/.hyper/uploads while a
+// `volume init` is in progress, so that an interrupted upload can be
+// resumed by running the same command again.
+type volumeUploadSession struct {
+	Session string
+	Cookie  string
+	Created time.Time
+	Volumes map[string]*volumeUploadState
+
+	mu   sync.Mutex
+	path string
+}
+
+// volumeUploadSessionPath returns the file holding the upload session of
+// a `volume init` invocation, keyed by its SOURCE:VOLUME arguments.
+func volumeUploadSessionPath(args []string) string {
+	sorted := append([]string{}, args...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return filepath.Join(cliconfig.ConfigDir(), "uploads", hex.EncodeToString(sum[:])+".json")
+}
+
+func loadVolumeUploadSession(path string) (*volumeUploadSession, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	session := &volumeUploadSession{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, err
+	}
+	session.path = path
+	return session, nil
+}
+
+func (s *volumeUploadSession) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	return copyToFile(s.path, bytes.NewReader(data))
+}
+
+func (s *volumeUploadSession) remove() {
+	os.Remove(s.path)
+}
+
+func (s *volumeUploadSession) markUploaded(state *volumeUploadState, chunk int) error {
+	s.mu.Lock()
+	state.Uploaded = append(state.Uploaded, chunk)
+	s.mu.Unlock()
+	return s.save()
+}
+
+// uploadError is returned for chunks the server refused.
+type uploadError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e uploadError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s: %s", http.StatusText(e.StatusCode), e.Message)
+	}
+	return http.StatusText(e.StatusCode)
+}
+
+// temporary reports whether retrying the request may succeed.
+func (e uploadError) temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout || e.StatusCode == statusTooManyRequests
+}
+
+// sessionExpired reports whether err means the server no longer knows the
+// upload session, which makes resuming impossible.
+func sessionExpired(err error) bool {
+	if e, ok := err.(uploadError); ok {
+		return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+	}
+	return false
+}
+
+var uploadHTTPClient = &http.Client{}
+
+// sendChunk uploads data at offset of the tar stream of a volume. The
+// server verifies the chunk against its SHA256 before accepting it.
+func sendChunk(uri, cookie string, offset, total int64, data []byte, limiter *rateLimiter) error {
+	sum := sha256.Sum256(data)
+	query := url.Values{}
+	query.Set("cookie", cookie)
+	query.Set("offset", fmt.Sprintf("%d", offset))
+	query.Set("total", fmt.Sprintf("%d", total))
+
+	var body io.Reader = bytes.NewReader(data)
+	if limiter != nil {
+		body = &rateLimitedReader{r: body, limiter: limiter}
+	}
+	req, err := http.NewRequest("POST", uri+"?"+query.Encode(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Type", "application/x-tar")
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(len(data))-1, total))
+	req.Header.Set("X-Hyper-Chunk-Sha256", hex.EncodeToString(sum[:]))
+	return doUploadRequest(req)
+}
+
+// completeUpload tells the server that all the chunks of a volume were
+// sent, along with the SHA256 of the whole tar stream.
+func completeUpload(uri, cookie string, total int64, digest string) error {
+	query := url.Values{}
+	query.Set("cookie", cookie)
+	query.Set("total", fmt.Sprintf("%d", total))
+	query.Set("complete", "true")
+	req, err := http.NewRequest("POST", uri+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Hyper-Content-Sha256", digest)
+	return doUploadRequest(req)
+}
+
+func doUploadRequest(req *http.Request) error {
+	resp, err := uploadHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		return uploadError{StatusCode: resp.StatusCode, Message: buf.String()}
+	}
+	return nil
+}
+
+// temporaryError is implemented by the errors telling whether retrying
+// may succeed.
+type temporaryError interface {
+	temporary() bool
+}
+
+// withRetry calls fn until it succeeds, fails with a permanent error or
+// retries are exhausted, backing off exponentially between attempts.
+func withRetry(retries int, fn func() error) error {
+	delay := time.Second
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if e, ok := err.(temporaryError); ok && !e.temporary() {
+			return err
+		}
+		if attempt >= retries {
+			return err
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > maxUploadRetryDelay {
+			delay = maxUploadRetryDelay
+		}
+	}
+}
+
+type uploadChunk struct {
+	index  int
+	offset int64
+	data   []byte
+}
+
+// uploadChunks sends the tar stream of a volume in chunks, skipping those
+// already recorded in state. The stream is read sequentially so that its
+// SHA256 can be computed while at most opts.parallel chunks are in flight.
+func uploadChunks(tar *TarFile, state *volumeUploadState, session *volumeUploadSession, opts *volumeUploadOptions, bar *pb.ProgressBar) error {
+	done := make(map[int]bool, len(state.Uploaded))
+	for _, idx := range state.Uploaded {
+		done[idx] = true
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		chunks   = make(chan uploadChunk)
+		abort    = make(chan struct{})
+	)
+	setErr := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			close(abort)
+		}
+		mu.Unlock()
+	}
+
+	for i := 0; i < opts.parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				err := withRetry(opts.retries, func() error {
+					return sendChunk(state.URL, session.Cookie, c.offset, state.Size, c.data, opts.limiter)
+				})
+				if err == nil {
+					err = session.markUploaded(state, c.index)
+				}
+				if err != nil {
+					setErr(err)
+					continue
+				}
+				if bar != nil {
+					bar.Add(len(c.data))
+				}
+			}
+		}()
+	}
+
+	hash := sha256.New()
+	var readErr error
+read:
+	for idx, offset := 0, int64(0); offset < state.Size; idx, offset = idx+1, offset+state.ChunkSize {
+		size := state.ChunkSize
+		if remain := state.Size - offset; remain < size {
+			size = remain
+		}
+		data := make([]byte, size)
+		if _, err := tar.ReadAt(data, offset); err != nil && err != io.EOF {
+			readErr = err
+			break
+		}
+		hash.Write(data)
+		if done[idx] {
+			if bar != nil {
+				bar.Add(len(data))
+			}
+			continue
+		}
+		select {
+		case chunks <- uploadChunk{index: idx, offset: offset, data: data}:
+		case <-abort:
+			break read
+		}
+	}
+	close(chunks)
+	wg.Wait()
+
+	if readErr != nil {
+		return readErr
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+	return withRetry(opts.retries, func() error {
+		return completeUpload(state.URL, session.Cookie, state.Size, digest)
+	})
+}
@@ -3,12 +3,13 @@ package formatter
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	units "github.com/docker/go-units"
 )
 
 const (
-	defaultStatsTableFormat = "table {{.Container}}\t{{.CPUPerc}}\t{{.MemUsage}}\t{{.MemPerc}}\t{{.NetIO}}\t{{.BlockIO}}"
+	defaultStatsTableFormat = "table {{.Container}}\t{{.Size}}\t{{.CPUPerc}}\t{{.MemUsage}}\t{{.MemPerc}}\t{{.NetIO}}\t{{.BlockIO}}"
 
 	containerHeader = "CONTAINER"
 	cpuPercHeader   = "CPU %"
@@ -20,18 +21,24 @@ const (
 
 // StatsEntry represents represents the statistics data collected from a container
 type StatsEntry struct {
-	Container        string
-	Name             string
-	ID               string
-	CPUPercentage    float64
-	Memory           float64
-	MemoryLimit      float64
-	MemoryPercentage float64
-	NetworkRx        float64
-	NetworkTx        float64
-	BlockRead        float64
-	BlockWrite       float64
-	IsInvalid        bool
+	Container string `json:"container"`
+	Name      string `json:"name,omitempty"`
+	ID        string `json:"id,omitempty"`
+	Image     string `json:"image,omitempty"`
+	// Size is the Hyper instance type of the container, such as s4.
+	Size   string            `json:"size"`
+	Labels map[string]string `json:"-"`
+	// Read is the time the sample was taken at.
+	Read             time.Time `json:"read"`
+	CPUPercentage    float64   `json:"cpu_percentage"`
+	Memory           float64   `json:"memory"`
+	MemoryLimit      float64   `json:"memory_limit"`
+	MemoryPercentage float64   `json:"memory_percentage"`
+	NetworkRx        float64   `json:"network_rx"`
+	NetworkTx        float64   `json:"network_tx"`
+	BlockRead        float64   `json:"block_read"`
+	BlockWrite       float64   `json:"block_write"`
+	IsInvalid        bool      `json:"invalid"`
 }
 
 // ContainerStats represents an entity to store containers statistics synchronously
@@ -76,11 +83,28 @@ func (cs *ContainerStats) SetError(err error) {
 	}
 }
 
+// SetContainer sets the information of the container the statistics are
+// collected from. The size is taken from the instance type label.
+func (cs *ContainerStats) SetContainer(id, name, image string, labels map[string]string) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	cs.ID = id
+	cs.Name = name
+	cs.Image = image
+	cs.Labels = labels
+	cs.Size = labels["sh_hyper_instancetype"]
+}
+
 // SetStatistics set the container statistics
 func (cs *ContainerStats) SetStatistics(s StatsEntry) {
 	cs.mutex.Lock()
 	defer cs.mutex.Unlock()
 	s.Container = cs.Container
+	s.ID = cs.ID
+	s.Name = cs.Name
+	s.Image = cs.Image
+	s.Size = cs.Size
+	s.Labels = cs.Labels
 	cs.StatsEntry = s
 }
 
@@ -132,6 +156,14 @@ func (c *containerStatsContext) Container() string {
 	return c.s.Container
 }
 
+func (c *containerStatsContext) Size() string {
+	c.AddHeader(sizeHeader)
+	if c.s.Size == "" {
+		return "--"
+	}
+	return c.s.Size
+}
+
 func (c *containerStatsContext) CPUPerc() string {
 	c.AddHeader(cpuPercHeader)
 	if c.s.IsInvalid {
@@ -27,13 +27,13 @@ type Command struct {
 
 var dockerCommands = []Command{
 	{"attach", "Attach to a running container"},
-	//{"build", "Build an image from a Dockerfile"},
+	{"build", "Build an image from a Dockerfile"},
 	{"commit", "Create a new image from a container's changes"},
 	{"config", "Config access key and secret key to Hyper server"},
 	//{"cp", "Copy files/folders between a container and the local filesystem"},
 	{"create", "Create a new container"},
 	//{"diff", "Inspect changes on a container's filesystem"},
-	//{"events", "Get real time events from the server"},
+	{"events", "Get real time events from the server"},
 	{"exec", "Run a command in a running container"},
 	//{"export", "Export a container's filesystem as a tar archive"},
 	{"history", "Show the history of an image"},
@@ -50,7 +50,7 @@ var dockerCommands = []Command{
 	//{"pause", "Pause all processes within a container"},
 	{"port", "List port mappings or a specific mapping for the CONTAINER"},
 	{"ps", "List containers"},
-	{"pull", "Pull an image or a repository from a registry"},
+	{"pull", "Pull images or a repository from a registry"},
 	{"push", "Push an image or a repository to a registry"},
 	{"rename", "Rename a container"},
 	{"restart", "Restart a container"},
@@ -65,7 +65,7 @@ var dockerCommands = []Command{
 	//{"tag", "Tag an image into a repository"},
 	//{"top", "Display the running processes of a container"},
 	//{"unpause", "Unpause all processes within a container"},
-	{"update", "Update resources of one or more containers"},
+	{"update", "Update resources of one or more containers, or hyper"},
 	{"version", "Show the Hyper.sh version information"},
 	{"volume", "Manage Hyper.sh volumes"},
 	{"snapshot", "Manage Hyper.sh snapshots"},
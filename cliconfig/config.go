@@ -31,6 +31,9 @@ const (
 
 var (
 	configDir = os.Getenv("HYPER_CONFIG")
+
+	// HyperRegions are the regions of hyper.sh, listed by --all-regions.
+	HyperRegions = []string{"us-west-1", "eu-central-1"}
 )
 
 func init() {
@@ -57,14 +60,18 @@ type CloudConfig struct {
 
 // ConfigFile ~/.docker/config.json file info
 type ConfigFile struct {
-	AuthConfigs   map[string]types.AuthConfig `json:"auths"`
-	CloudConfig   map[string]CloudConfig      `json:"clouds"`
-	HTTPHeaders   map[string]string           `json:"HttpHeaders,omitempty"`
-	PsFormat      string                      `json:"psFormat,omitempty"`
-	ImagesFormat  string                      `json:"imagesFormat,omitempty"`
-	VolumesFormat string                      `json:"volumesFormat,omitempty"`
-	DetachKeys    string                      `json:"detachKeys,omitempty"`
-	filename      string                      // Note: not serialized - for internal use only
+	AuthConfigs     map[string]types.AuthConfig `json:"auths"`
+	CloudConfig     map[string]CloudConfig      `json:"clouds"`
+	HTTPHeaders     map[string]string           `json:"HttpHeaders,omitempty"`
+	PsFormat        string                      `json:"psFormat,omitempty"`
+	ImagesFormat    string                      `json:"imagesFormat,omitempty"`
+	VolumesFormat   string                      `json:"volumesFormat,omitempty"`
+	SnapshotsFormat string                      `json:"snapshotsFormat,omitempty"`
+	StatsFormat     string                      `json:"statsFormat,omitempty"`
+	DetachKeys      string                      `json:"detachKeys,omitempty"`
+	UpdateChannel   string                      `json:"updateChannel,omitempty"`
+	NoUpdateCheck   bool                        `json:"noUpdateCheck,omitempty"`
+	filename        string                      // Note: not serialized - for internal use only
 }
 
 // NewConfigFile initializes an empty configuration file for the given filename 'fn'
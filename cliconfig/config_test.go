@@ -177,7 +177,7 @@ email = user@example.com`
 	// defaultIndexserver is https://index.docker.io/v1/
 	ac := config.AuthConfigs["https://index.docker.io/v1/"]
 	if ac.Email != "user@example.com" || ac.Username != "joejoe" || ac.Password != "hello" {
-		t.Fatalf("Missing data from parsing:\n%q", config)
+		t.Fatalf("Missing data from parsing:\n%+v", config)
 	}
 
 	// Now save it and make sure it shows up in new form
@@ -240,7 +240,7 @@ func TestOldJson(t *testing.T) {
 
 	ac := config.AuthConfigs["https://index.docker.io/v1/"]
 	if ac.Email != "user@example.com" || ac.Username != "joejoe" || ac.Password != "hello" {
-		t.Fatalf("Missing data from parsing:\n%q", config)
+		t.Fatalf("Missing data from parsing:\n%+v", config)
 	}
 
 	// Now save it and make sure it shows up in new form
@@ -271,7 +271,7 @@ func TestNewJson(t *testing.T) {
 
 	ac := config.AuthConfigs["https://index.docker.io/v1/"]
 	if ac.Email != "user@example.com" || ac.Username != "joejoe" || ac.Password != "hello" {
-		t.Fatalf("Missing data from parsing:\n%q", config)
+		t.Fatalf("Missing data from parsing:\n%+v", config)
 	}
 
 	// Now save it and make sure it shows up in new form
@@ -367,7 +367,7 @@ func TestJsonReaderNoFile(t *testing.T) {
 
 	ac := config.AuthConfigs["https://index.docker.io/v1/"]
 	if ac.Email != "user@example.com" || ac.Username != "joejoe" || ac.Password != "hello" {
-		t.Fatalf("Missing data from parsing:\n%q", config)
+		t.Fatalf("Missing data from parsing:\n%+v", config)
 	}
 
 }
@@ -382,7 +382,7 @@ func TestOldJsonReaderNoFile(t *testing.T) {
 
 	ac := config.AuthConfigs["https://index.docker.io/v1/"]
 	if ac.Email != "user@example.com" || ac.Username != "joejoe" || ac.Password != "hello" {
-		t.Fatalf("Missing data from parsing:\n%q", config)
+		t.Fatalf("Missing data from parsing:\n%+v", config)
 	}
 }
 
@@ -479,3 +479,66 @@ func TestEncodeAuth(t *testing.T) {
 		t.Fatal("AuthString encoding isn't correct.")
 	}
 }
+
+func TestJsonWithStatsFormat(t *testing.T) {
+	tmpHome, err := ioutil.TempDir("", "config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpHome)
+
+	fn := filepath.Join(tmpHome, ConfigFileName)
+	js := `{
+		"auths": { "https://index.docker.io/v1/": { "auth": "am9lam9lOmhlbGxv", "email": "user@example.com" } },
+		"statsFormat": "table {{.Container}}\\t{{.Size}}\\t{{.CPUPerc}}"
+}`
+	if err := ioutil.WriteFile(fn, []byte(js), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := Load(tmpHome)
+	if err != nil {
+		t.Fatalf("Failed loading on empty json file: %q", err)
+	}
+
+	if config.StatsFormat != `table {{.Container}}\t{{.Size}}\t{{.CPUPerc}}` {
+		t.Fatalf("Unknown stats format: %s\n", config.StatsFormat)
+	}
+
+	configStr := saveConfigAndValidateNewFormat(t, config, tmpHome)
+	if !strings.Contains(configStr, `"statsFormat":`) {
+		t.Fatalf("Should have save in new form: %s", configStr)
+	}
+}
+
+func TestJsonWithUpdateConfig(t *testing.T) {
+	tmpHome, err := ioutil.TempDir("", "config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpHome)
+
+	fn := filepath.Join(tmpHome, ConfigFileName)
+	js := `{
+		"auths": { "https://index.docker.io/v1/": { "auth": "am9lam9lOmhlbGxv", "email": "user@example.com" } },
+		"updateChannel": "beta",
+		"noUpdateCheck": true
+}`
+	if err := ioutil.WriteFile(fn, []byte(js), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := Load(tmpHome)
+	if err != nil {
+		t.Fatalf("Failed loading on empty json file: %q", err)
+	}
+
+	if config.UpdateChannel != "beta" || !config.NoUpdateCheck {
+		t.Fatalf("Unknown update config: %s, %v\n", config.UpdateChannel, config.NoUpdateCheck)
+	}
+
+	configStr := saveConfigAndValidateNewFormat(t, config, tmpHome)
+	if !strings.Contains(configStr, `"updateChannel":`) || !strings.Contains(configStr, `"noUpdateCheck":`) {
+		t.Fatalf("Should have save in new form: %s", configStr)
+	}
+}
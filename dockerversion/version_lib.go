@@ -10,4 +10,7 @@ const (
 	Version   string = "library-import"
 	BuildTime string = "library-import"
 	IAmStatic string = "library-import"
+	// UpdatePublicKey is the base64 DER ECDSA public key the updates of
+	// hyper are signed with, self-update is disabled without it.
+	UpdatePublicKey string = ""
 )
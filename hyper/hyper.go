@@ -3,21 +3,23 @@ package main
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/hyperhq/hypercli/api/client"
 	"github.com/hyperhq/hypercli/cli"
+	"github.com/hyperhq/hypercli/cliconfig"
 	"github.com/hyperhq/hypercli/dockerversion"
-	"github.com/hyperhq/hypercli/pkg/homedir"
 	flag "github.com/hyperhq/hypercli/pkg/mflag"
 	"github.com/hyperhq/hypercli/pkg/reexec"
-	"github.com/hyperhq/hypercli/pkg/selfupdate"
 	"github.com/hyperhq/hypercli/pkg/term"
 	"github.com/hyperhq/hypercli/utils"
 )
 
+// updateNoticeTimeout is how long hyper waits for the update check once a
+// command is done, the notice is left out if the check takes longer.
+const updateNoticeTimeout = 300 * time.Millisecond
+
 func main() {
 	if reexec.Init() {
 		return
@@ -60,24 +62,10 @@ func main() {
 		flag.Usage()
 		return
 	}
-	var errChan = make(chan error, 1)
-	var update bool = false
-	var updater = &selfupdate.Updater{
-		CurrentVersion: dockerversion.Version,
-		ApiURL:         "https://hyper-update.s3.amazonaws.com/",
-		BinURL:         "https://hyper-update.s3.amazonaws.com/",
-		DiffURL:        "https://hyper-update.s3.amazonaws.com/",
-		Dir:            filepath.Join(homedir.Get(), ".hyper"),
-		CmdName:        "hyper", // app name
-	}
-
-	if updater != nil {
-		if update = updater.WantUpdate(); update {
-			go func() {
-				errChan <- updater.BackgroundRun(update)
-			}()
-		}
+	if clientFlags.ConfigDir != "" {
+		cliconfig.SetConfigDir(clientFlags.ConfigDir)
 	}
+	updateNotice := client.UpdateNotice()
 
 	clientCli := client.NewDockerCli(stdin, stdout, stderr, clientFlags)
 
@@ -94,14 +82,12 @@ func main() {
 		os.Exit(1)
 	}
 
-	if updater != nil && update {
-		fmt.Fprintln(os.Stdout, "Found a newer version, downloading...")
-		select {
-		case <-time.After(20 * time.Second):
-			break
-		case <-errChan:
-			break
+	select {
+	case notice, ok := <-updateNotice:
+		if ok {
+			fmt.Fprintln(stderr, notice)
 		}
+	case <-time.After(updateNoticeTimeout):
 	}
 }
 
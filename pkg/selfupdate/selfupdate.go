@@ -5,9 +5,18 @@
 //   200 ok
 //   {
 //       "Version": "2",
-//       "Sha256": "..." // base64
+//       "Sha256": "...", // base64
+//       "Channel": "stable"
 //   }
 //
+//   GET hk.heroku.com/hk/linux-amd64.json.sig
+//
+//   200 ok
+//   [base64 ECDSA signature of the json file]
+//
+// The signature is only fetched and verified if the Updater has a public
+// key. Channels other than stable are under hk.heroku.com/hk/<channel>/.
+//
 // then
 //
 //   GET hkpatch.s3.amazonaws.com/hk/1/2/linux-amd64
@@ -28,20 +37,27 @@ package selfupdate
 import (
 	"bytes"
 	"compress/gzip"
+	"crypto/ecdsa"
 	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/kardianos/osext"
 	"github.com/kr/binarydist"
+	"github.com/hyperhq/hypercli/pkg/version"
 	"gopkg.in/inconshreveable/go-update.v0"
 )
 
@@ -52,7 +68,14 @@ const (
 
 const devValidTime = 7 * 24 * time.Hour
 
+// StableChannel is the default release channel.
+const StableChannel = "stable"
+
 var ErrHashMismatch = errors.New("new file hash mismatch after patch")
+
+// ErrBadSignature is returned for update info not signed with the public
+// key of the Updater.
+var ErrBadSignature = errors.New("update info signature verification failed")
 var up = update.New()
 var defaultHTTPRequester = HTTPRequester{}
 
@@ -86,10 +109,49 @@ type Updater struct {
 	Dir            string    // Directory to store selfupdate state.
 	ForceCheck     bool      // Check for update regardless of cktime timestamp
 	Requester      Requester //Optional parameter to override existing http request handler
-	Info           struct {
+	Channel        string    // Release channel, StableChannel if empty.
+	// PublicKey is the key the update info must be signed with. Unsigned
+	// update info is accepted if nil.
+	PublicKey *ecdsa.PublicKey
+	Info      struct {
 		Version string
 		Sha256  []byte
+		Channel string
+	}
+}
+
+// ParsePublicKey parses a base64 encoded DER ECDSA public key, as the
+// PublicKey of an Updater.
+func ParsePublicKey(s string) (*ecdsa.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("update public key is not an ECDSA key")
 	}
+	return pub, nil
+}
+
+func (u *Updater) channel() string {
+	if u.Channel == "" {
+		return StableChannel
+	}
+	return u.Channel
+}
+
+// infoURL returns the URL of the update info of the channel.
+func (u *Updater) infoURL() string {
+	dir := u.ApiURL + u.CmdName + "/"
+	if c := u.channel(); c != StableChannel {
+		dir += c + "/"
+	}
+	return dir + plat + ".json"
 }
 
 func (u *Updater) getExecRelativeDir(dir string) string {
@@ -98,6 +160,23 @@ func (u *Updater) getExecRelativeDir(dir string) string {
 	return path
 }
 
+// Check fetches the update info of the channel and reports whether it is
+// newer than the current version.
+func (u *Updater) Check() (bool, error) {
+	if err := u.fetchInfo(); err != nil {
+		return false, err
+	}
+	return newerVersion(u.Info.Version, u.CurrentVersion), nil
+}
+
+// Apply replaces the running executable with the version found by Check.
+func (u *Updater) Apply() error {
+	if err := up.CanUpdate(); err != nil {
+		return err
+	}
+	return u.update()
+}
+
 // BackgroundRun starts the update check and apply cycle.
 func (u *Updater) BackgroundRun(update bool) error {
 	if _, err := os.Stat(u.Dir); err != nil && os.IsNotExist(err) {
@@ -127,18 +206,37 @@ func (u *Updater) WantUpdate() bool {
 		//log.Println("not update")
 		return false
 	}
-	defer func() {
-		wait := 24*time.Hour + randDuration(24*time.Hour)
-		writeTime(path, time.Now().Add(wait))
-	}()
+	// the next check is planned before fetching, so that a check cut short
+	// by the exit of hyper isn't retried by every command
+	wait := 24*time.Hour + randDuration(24*time.Hour)
+	writeTime(path, time.Now().Add(wait))
 	if u.fetchInfo() != nil {
 		return false
 	}
-	if u.Info.Version == u.CurrentVersion {
-		return false
+	return newerVersion(u.Info.Version, u.CurrentVersion)
+}
+
+// newerVersion reports whether v is newer than current. Versions are
+// numbers separated by dots, optionally followed by a pre-release suffix
+// like -beta1, older than the version without suffix.
+func newerVersion(v, current string) bool {
+	base, pre := splitPreRelease(v)
+	curBase, curPre := splitPreRelease(current)
+	if base != curBase {
+		return version.Version(base).GreaterThan(version.Version(curBase))
 	}
+	if pre == "" || curPre == "" {
+		return pre == "" && curPre != ""
+	}
+	return pre > curPre
+}
 
-	return true
+func splitPreRelease(v string) (string, string) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.Index(v, "-"); i >= 0 {
+		return v[:i], v[i+1:]
+	}
+	return v, ""
 }
 
 func (u *Updater) update() error {
@@ -197,21 +295,53 @@ func (u *Updater) update() error {
 }
 
 func (u *Updater) fetchInfo() error {
-	r, err := u.fetch(u.ApiURL + u.CmdName + "/" + plat + ".json")
+	url := u.infoURL()
+	data, err := u.fetchAll(url)
 	if err != nil {
 		return err
 	}
-	defer r.Close()
-	err = json.NewDecoder(r).Decode(&u.Info)
+	if u.PublicKey != nil {
+		sig, err := u.fetchAll(url + ".sig")
+		if err != nil {
+			return err
+		}
+		if !verifySignature(u.PublicKey, data, sig) {
+			return ErrBadSignature
+		}
+	}
+	err = json.Unmarshal(data, &u.Info)
 	if err != nil {
 		return err
 	}
 	if len(u.Info.Sha256) != sha256.Size {
 		return errors.New("bad cmd hash in info")
 	}
+	// the info of another channel could be served with a valid signature
+	if u.PublicKey != nil && u.Info.Channel != u.channel() {
+		return fmt.Errorf("update info is for the %q channel, expected %q", u.Info.Channel, u.channel())
+	}
 	return nil
 }
 
+// ecdsaSignature is the ASN.1 structure of an ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// verifySignature checks a base64 ECDSA signature of the SHA256 of data.
+func verifySignature(pub *ecdsa.PublicKey, data, sig []byte) bool {
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return false
+	}
+	var s ecdsaSignature
+	if rest, err := asn1.Unmarshal(der, &s); err != nil || len(rest) > 0 || s.R == nil || s.S == nil {
+		return false
+	}
+	h := sha256.Sum256(data)
+	return ecdsa.Verify(pub, h[:], s.R, s.S)
+}
+
 func (u *Updater) fetchAndVerifyPatch(old io.Reader) ([]byte, error) {
 	bin, err := u.fetchAndApplyPatch(old)
 	if err != nil {
@@ -269,6 +399,15 @@ func randDuration(n time.Duration) time.Duration {
 	return time.Duration(rand.Int63n(int64(n)))
 }
 
+func (u *Updater) fetchAll(url string) ([]byte, error) {
+	r, err := u.fetch(url)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
 func (u *Updater) fetch(url string) (io.ReadCloser, error) {
 	if u.Requester == nil {
 		return defaultHTTPRequester.Fetch(url)
@@ -2,10 +2,22 @@ package selfupdate
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 var testHash = sha256.New()
@@ -17,7 +29,7 @@ func TestUpdaterFetchMustReturnNonNilReaderCloser(t *testing.T) {
 			return nil, nil
 		})
 	updater := createUpdater(mr)
-	err := updater.BackgroundRun()
+	_, err := updater.Check()
 	if err != nil {
 		equals(t, "Fetch was expected to return non-nil ReadCloser", err.Error())
 	} else {
@@ -31,16 +43,124 @@ func TestUpdaterWithEmptyPaloadNoErrorNoUpdate(t *testing.T) {
 	mr := &mockRequester{}
 	mr.handleRequest(
 		func(url string) (io.ReadCloser, error) {
-			equals(t, "http://updates.yourdomain.com/myapp/darwin-amd64.json", url)
+			equals(t, "http://updates.yourdomain.com/myapp/"+plat+".json", url)
 			return newTestReaderCloser("{}"), nil
 		})
 	updater := createUpdater(mr)
+	updater.ForceCheck = true
 
-	err := updater.BackgroundRun()
+	if updater.WantUpdate() {
+		t.Error("Expected no update for an empty payload")
+	}
+
+}
+
+func TestWantUpdatePlansNextCheckBeforeFetching(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selfupdate-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mr := &mockRequester{}
+	mr.handleRequest(
+		func(url string) (io.ReadCloser, error) {
+			if !readTime(filepath.Join(dir, upcktimePath)).After(time.Now()) {
+				t.Error("Expected the next check to be planned before fetching")
+			}
+			return nil, fmt.Errorf("connection reset")
+		})
+	updater := createUpdater(mr)
+	updater.Dir = dir
+
+	if updater.WantUpdate() {
+		t.Error("Expected no update for a failed check")
+	}
+	if updater.WantUpdate() {
+		t.Error("Expected no update before the next check")
+	}
+}
+
+// signedInfo returns update info for a channel and its signature.
+func signedInfo(t *testing.T, key *ecdsa.PrivateKey, version, channel string) (string, string) {
+	sum := sha256.Sum256([]byte("binary"))
+	info, err := json.Marshal(map[string]interface{}{"Version": version, "Sha256": sum[:], "Channel": channel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.Sum256(info)
+	r, s, err := ecdsa.Sign(rand.Reader, key, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := asn1.Marshal(ecdsaSignature{r, s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(info), base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestUpdaterSignedInfo(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
-		t.Errorf("Error occured: %#v", err)
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ParsePublicKey(base64.StdEncoding.EncodeToString(der))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(info, sig string) (bool, error) {
+		mr := &mockRequester{}
+		mr.handleRequest(func(url string) (io.ReadCloser, error) {
+			equals(t, "http://updates.yourdomain.com/myapp/beta/"+plat+".json", url)
+			return newTestReaderCloser(info), nil
+		})
+		mr.handleRequest(func(url string) (io.ReadCloser, error) {
+			equals(t, "http://updates.yourdomain.com/myapp/beta/"+plat+".json.sig", url)
+			return newTestReaderCloser(sig), nil
+		})
+		updater := createUpdater(mr)
+		updater.Channel = "beta"
+		updater.PublicKey = pub
+		return updater.Check()
+	}
+
+	info, sig := signedInfo(t, key, "1.3-beta1", "beta")
+	if available, err := check(info, sig); err != nil || !available {
+		t.Fatalf("Expected an update, got %v, %v", available, err)
 	}
+	if _, err := check(strings.Replace(info, "1.3", "1.4", 1), sig); err != ErrBadSignature {
+		t.Fatalf("Expected tampered info to be refused, got %v", err)
+	}
+	info, sig = signedInfo(t, key, "1.3", "stable")
+	if _, err := check(info, sig); err == nil {
+		t.Fatal("Expected the info of another channel to be refused")
+	}
+}
 
+func TestNewerVersion(t *testing.T) {
+	cases := []struct {
+		v, current string
+		newer      bool
+	}{
+		{"1.10.16", "1.10.15", true},
+		{"1.10.15", "1.10.15", false},
+		{"1.10.9", "1.10.15", false},
+		{"1.11.0-beta1", "1.10.15", true},
+		{"1.11.0", "1.11.0-beta2", true},
+		{"1.11.0-beta2", "1.11.0-beta1", true},
+		{"1.11.0-beta1", "1.11.0", false},
+	}
+	for _, c := range cases {
+		if newer := newerVersion(c.v, c.current); newer != c.newer {
+			t.Errorf("newerVersion(%q, %q) = %v, expected %v", c.v, c.current, newer, c.newer)
+		}
+	}
 }
 
 func createUpdater(mr *mockRequester) *Updater {